@@ -1,17 +1,24 @@
 package gpc
 
 import (
-	"fmt"
+	"context"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type data struct {
-	method         string
-	param          interface{}
-	result         interface{}
-	errChan        chan error
-	startTimerChan chan *time.Timer
+	ctx      context.Context // 调用方的上下文，取消或超时后消息在处理前被丢弃
+	sender   Actor           // 发送者，从其他gpc的处理函数中发出时不为nil
+	method   string
+	param    interface{}
+	deadline time.Time           // 调用超时的时间点，没有超时为零值
+	future   *Future             // 同步调用的结果和回复参数，Go调用时为nil
+	fn       func()              // 在gpc协程中执行的函数，用于异步调用的回调
+	call     func(d *data) error // 类型安全的调用，代替callMethodFunc和goMethodFunc执行
+	priority int                 // 优先级，决定放入的通道，优先级队列的邮箱中先处理高的
+	try      bool                // TryGo或TryCall，邮箱满时不阻塞
 }
 
 const (
@@ -19,149 +26,648 @@ const (
 	GPC_CALL_TIMEOUT    = 1000 // 默認調用超時爲1000毫秒
 	GPC_CALL_NO_TIMEOUT = -1   // 沒有超時
 	GPC_TICK_MS         = 10   // 定时器函数调用间隔
+	GPC_RUN_BATCH       = 64   // Run每次连续处理的最多消息数，之后再检查定时器和关闭
 )
 
 // Precompute the reflect type for error. Can't use error directly
 // because Typeof takes an empty interface value. This is annoying.
 var typeOfError = reflect.TypeOf((*error)(nil)).Elem()
 
+// gpc的通用接口，GPC和GPCFast都实现了该接口
+type Actor interface {
+	Go(methodName string, param interface{})
+	GoContext(ctx context.Context, methodName string, param interface{}) error
+	Call(methodName string, param interface{}, result interface{}) error
+	CallContext(ctx context.Context, methodName string, param interface{}, result interface{}) error
+	GoAfter(delay time.Duration, methodName string, param interface{}) *Timer
+	GoAt(at time.Time, methodName string, param interface{}) *Timer
+	Every(interval time.Duration, methodName string, param interface{}) *Timer
+	TryGo(methodName string, param interface{}) error
+	TryCall(methodName string, param interface{}, result interface{}) error
+	GoPriority(priority int, methodName string, param interface{}) error
+	CallPriority(priority int, methodName string, param interface{}, result interface{}) error
+	Watch(target Actor)
+	Unwatch(target Actor)
+	Run()
+	Close()
+	Stop(ctx context.Context) error
+	Done() <-chan struct{}
+	Err() error
+	base() *gpcBase
+}
+
 // gpc的基础结构，封装了基础功能
 type gpcBase struct {
-	options        Options
-	ch             chan *data
-	callMethodFunc func(string, interface{}, interface{}) error
-	goMethodFunc   func(string, interface{})
-	tickMethodFunc func(tick int32)
-	closeChan      chan struct{}
+	options         Options
+	clock           Clock // 调用超时、定时器函数和定时消息使用的时钟
+	self            Actor // 包含gpcBase的GPC或GPCFast
+	mailbox         Mailbox
+	highLane        Mailbox       // 高优先级的消息，使用MailboxPriority时为nil，都放入mailbox
+	systemLane      Mailbox       // 紧急的系统消息，无界，最先处理
+	readyChan       chan struct{} // 邮箱中放入了消息
+	spaceChan       chan struct{} // 邮箱中取出消息时关闭并重新创建，唤醒等待空位的发送方，由spaceLocker保护
+	spaceLocker     sync.Mutex
+	spaceWaiters    int32  // 等待空位的发送方数，原子操作
+	dropped         uint64 // 邮箱满时丢弃的消息数，原子操作
+	callMethodFunc  func(*data) error
+	goMethodFunc    func(*data)
+	tickMethodFunc  func(tick int32)
+	frameMethodFunc func(frame int64, dt int32) // 固定步长模式的定时器函数
+	restartFunc     func() error                // 用工厂重新创建服务
+	onStartFunc     func(ctx context.Context) error
+	onStopFunc      func(reason error)
+	onRestartFunc   func(err error)
+	onPassivateFunc func()
+	onTerminateFunc func(t Terminated)
+	priorities      map[string]int // 注册时设置的方法优先级，创建后只读
+	closeChan       chan struct{}
+	closeOnce       sync.Once
+	sendLocker      sync.RWMutex // 发送时持有读锁，Run退出前持有写锁设置closing，保证之后没有消息入队
+	closing         bool
+	abortChan       chan struct{} // 关闭时放弃处理剩余的消息
+	abortOnce       sync.Once
+	stopped         bool  // Run已退出或将要退出，只在Run所在协程中访问
+	stopReason      error // Run退出的原因，正常关闭为nil
+	doneChan        chan struct{}
+	exitLocker      sync.Mutex
+	exited          bool
+	exitHooks       []func(reason error)
+	watchers        map[*gpcBase]struct{} // 监视本gpc的gpc，由exitLocker保护
+	watching        map[*gpcBase]struct{} // 本gpc监视的gpc，由watchLocker保护
+	watchLocker     sync.Mutex
+	parent          *gpcBase   // 由Spawn创建时的父gpc
+	children        []*gpcBase // 还在运行的子gpc，由childLocker保护
+	childrenStopped bool       // 已经停止子gpc，不能再创建
+	childLocker     sync.Mutex
+	processed       uint64     // 开始处理的消息数，原子操作
+	grouped         int32      // 加入了TickGroup，不再自己调用定时器函数，原子操作
+	lastTick        time.Time  // 上一次调用定时器函数的时间
+	frames          frameClock // 固定步长模式的帧计时
+	passivating     int32      // 被VirtualRegistry钝化，原子操作
+	execState       int32      // 在Executor中的状态，原子操作
+	started         bool       // 在Executor中已经开始运行
+	startFailed     bool       // 在Executor中OnStart返回了错误
+	ticker          ClockTimer // 在Executor中投递定时器消息的计时器
+	current         *data      // 正在处理的消息，只在Run所在协程中访问
+	waiting         *waitEdge  // 正在等待的同步调用，由deadlockLocker保护
 }
 
 // 初始化
 func (g *gpcBase) init(
-	callMethod func(string, interface{}, interface{}) error,
-	goMethod func(string, interface{}),
+	self Actor,
+	callMethod func(*data) error,
+	goMethod func(*data),
 	tickMethod func(tick int32)) {
 	if g.options.chLen <= 0 {
 		g.options.chLen = GPC_CHANNEL_LEN
 	}
-	g.ch = make(chan *data, g.options.chLen)
+	if g.options.overflow == OverflowShedLowPriority && g.options.mailboxType != MailboxPriority {
+		panic("gpc: OverflowShedLowPriority needs MailboxPriority")
+	}
+	g.mailbox = newMailbox(g.options.mailboxType, g.options.chLen)
+	if g.options.mailboxType != MailboxPriority {
+		g.highLane = &linkedMailbox{capacity: g.options.chLen}
+	}
+	g.systemLane = &linkedMailbox{}
+	g.priorities = g.options.priorities
+	g.readyChan = make(chan struct{}, 1)
+	g.spaceChan = make(chan struct{})
 	if g.options.callTimeout == 0 {
 		g.options.callTimeout = GPC_CALL_TIMEOUT
 	}
 	if g.options.tickMs == 0 {
 		g.options.tickMs = GPC_TICK_MS
 	}
+	if g.options.timerResolution <= 0 {
+		g.options.timerResolution = GPC_TIMER_RESOLUTION_MS
+	}
+	if g.options.clock == nil {
+		g.options.clock = RealClock(time.Duration(g.options.timerResolution) * time.Millisecond)
+	}
+	g.clock = g.options.clock
+	g.self = self
 	// 在这里给Run中调用的处理函数赋值，目前没有更好的方法，这算是最简单的做法了
 	g.callMethodFunc = callMethod
 	g.goMethodFunc = goMethod
 	g.tickMethodFunc = tickMethod
 	g.closeChan = make(chan struct{})
+	g.abortChan = make(chan struct{})
+	g.doneChan = make(chan struct{})
 }
 
 // 外部调用无返回值的方法
 func (g *gpcBase) Go(methodName string, param interface{}) {
+	g.GoContext(context.Background(), methodName, param)
+}
+
+// 带上下文的无返回值调用，入队时遵守ctx的取消和超时，ctx失效后已入队的消息也不会再执行
+func (g *gpcBase) GoContext(ctx context.Context, methodName string, param interface{}) error {
 	// 调用数据
 	d := &data{
+		ctx:    ctx,
+		sender: senderFrom(ctx),
 		method: methodName,
 		param:  param,
 	}
-	g.ch <- d
+	return g.goData(ctx, d)
 }
 
-// 用于外部调用的方法，同步调用
+// 投递无返回值调用的消息
+func (g *gpcBase) goData(ctx context.Context, d *data) error {
+	if err := ctx.Err(); err != nil {
+		return callError(d.method, err)
+	}
+	// 没有截止时间时不读取时钟
+	if remaining, ok := ctxRemaining(ctx); ok {
+		d.deadline = g.clock.Now().Add(remaining)
+	}
+	return callError(d.method, g.send(ctx, d))
+}
+
+// 用于外部调用的方法，同步调用，超时由选项callTimeout决定
 func (g *gpcBase) Call(methodName string, param interface{}, result interface{}) (err error) {
+	return g.CallAsync(methodName, param, result).Wait()
+}
+
+// 带上下文的同步调用，入队、排队和等待结果的过程中都遵守ctx的取消和超时
+func (g *gpcBase) CallContext(ctx context.Context, methodName string, param interface{}, result interface{}) error {
+	return g.callAsync(ctx, methodName, param, result, GPC_CALL_NO_TIMEOUT).wait(ctx)
+}
+
+// 异步调用，立即返回调用结果的Future，超时由选项callTimeout决定
+// 在Future完成之前不能读写result
+func (g *gpcBase) CallAsync(methodName string, param interface{}, result interface{}) *Future {
+	return g.callAsync(context.Background(), methodName, param, result, g.options.callTimeout)
+}
+
+// 异步调用的实现，timeout为毫秒，超时从调用开始计算，包括在通道中等待的时间
+func (g *gpcBase) callAsync(ctx context.Context, methodName string, param interface{}, result interface{}, timeout int) *Future {
 	if result == nil {
 		panic("gpc: Call result param cant be nil")
 	}
+	// 调用数据
+	d := &data{
+		ctx:    ctx,
+		sender: senderFrom(ctx),
+		method: methodName,
+		param:  param,
+	}
+	return g.callData(ctx, d, result, timeout)
+}
+
+// 投递同步调用的消息，返回调用结果的Future，result为处理函数的回复参数
+func (g *gpcBase) callData(ctx context.Context, d *data, result interface{}, timeout int) *Future {
+	methodName := d.method
+	future := newFuture(g, methodName)
+	future.result = result
+	d.future = future
+	if err := ctx.Err(); err != nil {
+		future.complete(callError(methodName, err))
+		return future
+	}
+	// 截止时间取上下文和调用超时中较早的一个
+	var timeoutErr error
+	now := g.clock.Now()
+	if remaining, ok := ctxRemaining(ctx); ok {
+		d.deadline = now.Add(remaining)
+		timeoutErr = callError(methodName, context.DeadlineExceeded)
+	}
+	if timeout >= 0 {
+		deadline := now.Add(time.Duration(timeout) * time.Millisecond)
+		if d.deadline.IsZero() || deadline.Before(d.deadline) {
+			d.deadline = deadline
+			timeoutErr = callError(methodName, ErrTimeout)
+		}
+	}
+	if !d.deadline.IsZero() {
+		future.setTimeout(d.deadline.Sub(now), timeoutErr)
+	}
+	if err := g.send(ctx, d); err != nil {
+		future.complete(callError(methodName, err))
+	}
+	return future
+}
 
-	// 错误通道
-	errChan := make(chan error)
-	defer close(errChan)
+// 向target发起异步调用，结果返回后callback作为消息投递到本gpc的通道，在Run所在的协程中执行
+// 处理函数中调用其他gpc时使用，不会阻塞Run，gpc之间互相调用也不会死锁
+func (g *gpcBase) Ask(target Actor, methodName string, param interface{}, result interface{}, callback func(err error)) {
+	ctx := context.WithValue(context.Background(), actorKey{}, g.self)
+	g.ask(ctx, target, methodName, param, result, callback)
+}
 
-	// 调用超时通道
-	var startTimerChan chan *time.Timer
-	if g.options.callTimeout >= 0 {
-		startTimerChan = make(chan *time.Timer)
-		defer close(startTimerChan)
+func (g *gpcBase) ask(ctx context.Context, target Actor, methodName string, param interface{}, result interface{}, callback func(err error)) {
+	t := target.base()
+	t.callAsync(ctx, methodName, param, result, t.options.callTimeout).PipeTo(g.self, callback)
+}
+
+// 投递一个在Run所在协程中执行的函数，邮箱满时不阻塞调用方
+func (g *gpcBase) post(fn func()) {
+	g.postData(&data{
+		ctx: context.Background(),
+		fn:  fn,
+	})
+}
+
+// 投递消息，邮箱满时不阻塞调用方，已关闭时丢弃
+func (g *gpcBase) postData(d *data) {
+	g.sendLocker.RLock()
+	defer g.sendLocker.RUnlock()
+	if g.closing {
+		return
 	}
+	if !g.push(d) {
+		go g.send(d.ctx, d)
+	}
+}
 
-	// 调用数据
-	d := &data{
-		method:         methodName,
-		param:          param,
-		result:         result,
-		errChan:        errChan,
-		startTimerChan: startTimerChan,
-	}
-	g.ch <- d
-
-	// 调用超时检测
-	if startTimerChan != nil {
-		// 取出计时器
-		timer := <-startTimerChan
-		// 等待結果的處理
-		select {
-		// todo 注意这里的time.Time通道会在超时后由计时器内部关闭
-		case <-timer.C:
-			err = fmt.Errorf("gpc: call method (%v) timeout", methodName)
-			// 等待错误结果
-		case err = <-errChan:
+// 把调用数据放入邮箱，邮箱满时按选项的策略处理，默认阻塞直到有空位、ctx结束、调用超时或关闭
+func (g *gpcBase) send(ctx context.Context, d *data) error {
+	g.sendLocker.RLock()
+	defer g.sendLocker.RUnlock()
+	if g.closing {
+		return g.closedError(d.method)
+	}
+	d.priority = g.priorityOf(ctx, d)
+	if g.push(d) {
+		return nil
+	}
+	return g.overflow(ctx, d)
+}
+
+// 等待邮箱有空位后放入，timeout关闭时返回ErrMailboxFull
+func (g *gpcBase) wait(ctx context.Context, d *data, timeout <-chan struct{}) error {
+	var expired <-chan struct{}
+	if d.future != nil {
+		expired = d.future.Done()
+	}
+	for {
+		space := g.waitSpace()
+		// 登记之后再试一次，避免错过登记之前取出消息的唤醒
+		pushed := g.push(d)
+		var err error
+		if !pushed {
+			select {
+			case <-space:
+			case <-ctx.Done():
+				err = ctx.Err()
+			case <-expired:
+				// 调用已经以超时完成
+				pushed = true
+			case <-timeout:
+				err = ErrMailboxFull
+			case <-g.closeChan:
+				err = g.closedError(d.method)
+			}
+		}
+		atomic.AddInt32(&g.spaceWaiters, -1)
+		if pushed || err != nil {
+			return err
 		}
-	} else { // 只有错误结果
-		err = <-errChan
+		if g.push(d) {
+			return nil
+		}
+	}
+}
+
+// 放入邮箱并通知Run或Executor，邮箱满时返回false
+func (g *gpcBase) push(d *data) bool {
+	if !g.laneOf(d).Push(d) {
+		return false
+	}
+	select {
+	case g.readyChan <- struct{}{}:
+	default:
+	}
+	g.notify()
+	return true
+}
+
+// 依次从系统通道、高优先级通道和邮箱中取出一条消息，唤醒等待空位的发送方
+func (g *gpcBase) pop() *data {
+	d := g.systemLane.Pop()
+	if d == nil && g.highLane != nil {
+		d = g.highLane.Pop()
+	}
+	if d == nil {
+		d = g.mailbox.Pop()
+	}
+	if d != nil && atomic.LoadInt32(&g.spaceWaiters) > 0 {
+		g.spaceLocker.Lock()
+		close(g.spaceChan)
+		g.spaceChan = make(chan struct{})
+		g.spaceLocker.Unlock()
 	}
+	return d
+}
 
-	return err
+// 登记等待空位，返回有空位时关闭的通道
+func (g *gpcBase) waitSpace() <-chan struct{} {
+	atomic.AddInt32(&g.spaceWaiters, 1)
+	g.spaceLocker.Lock()
+	defer g.spaceLocker.Unlock()
+	return g.spaceChan
 }
 
 // 循环执行，为了不阻塞调用的goroutine，一般要加上go关键字再执行
+// 使用Executor的gpc交给Executor执行后立即返回，用Done等待退出
 func (g *gpcBase) Run() {
-	var ticker *time.Ticker
-	if g.tickMethodFunc != nil {
-		ticker = time.NewTicker(time.Duration(g.options.tickMs * int32(time.Millisecond)))
-	}
-	lastTime := time.Now()
-	isRun := true
-	if ticker != nil {
-		for isRun {
-			select {
-			case d := <-g.ch:
-				g.process(d)
-			case <-ticker.C:
-				now := time.Now()
-				tick := now.Sub(lastTime)
-				g.tickMethodFunc(int32(tick.Milliseconds()))
-				lastTime = now
-			case <-g.closeChan:
-				isRun = false
+	if g.options.executor != nil {
+		g.options.executor.start(g)
+		return
+	}
+	// 记录执行的协程，用于检测死锁
+	goid := goroutineID()
+	actorGoroutines.Store(goid, g)
+	defer actorGoroutines.Delete(goid)
+	defer g.exit()
+
+	started := g.begin()
+	var tickChan <-chan time.Time
+	if g.hasTick() {
+		var ticker ClockTimer
+		tickChan, ticker = g.clock.NewTicker(time.Duration(g.options.tickMs) * time.Millisecond)
+		defer ticker.Stop()
+	}
+	var pending *data
+	for !g.stopped {
+		select {
+		case <-g.readyChan:
+			for i := 0; i < GPC_RUN_BATCH && !g.stopped; i++ {
+				d := g.pop()
+				if d == nil {
+					break
+				}
+				// 已经关闭时交给shutdown按选项处理
+				select {
+				case <-g.closeChan:
+					g.stopped = true
+					pending = d
+				default:
+					g.process(d)
+				}
 			}
+			// 还有消息时下一轮继续处理
+			if !g.stopped && g.queued() > 0 {
+				select {
+				case g.readyChan <- struct{}{}:
+				default:
+				}
+			}
+		case <-tickChan:
+			g.onTick(g.clock.Now())
+		case <-g.closeChan:
+			g.stopped = true
 		}
+	}
+	g.end(pending, started)
+}
+
+// 开始运行，调用OnStart，返回是否成功
+func (g *gpcBase) begin() bool {
+	if err := g.callOnStart(); err != nil {
+		g.stopped = true
+		g.stopReason = err
+		return false
+	}
+	g.lastTick = g.clock.Now()
+	g.frames = frameClock{start: g.lastTick}
+	return true
+}
+
+// 结束运行，处理剩余的消息并停止子gpc后调用OnStop
+func (g *gpcBase) end(pending *data, started bool) {
+	g.shutdown(pending)
+	g.stopChildren()
+	if started {
+		g.callOnPassivate()
+		g.callOnStop(g.stopReason)
+	}
+}
+
+// 是否有定时器函数
+func (g *gpcBase) hasTick() bool {
+	return g.tickMethodFunc != nil || g.frameMethodFunc != nil
+}
+
+// 到了调用定时器函数的时间
+func (g *gpcBase) onTick(now time.Time) {
+	if atomic.LoadInt32(&g.grouped) != 0 {
+		// 由TickGroup驱动
+	} else if g.options.fixedStep {
+		g.tickFrames(&g.frames, now)
 	} else {
-		for isRun {
+		tick := now.Sub(g.lastTick)
+		g.tick(int32(tick.Milliseconds()))
+	}
+	g.lastTick = now
+}
+
+// Run退出前调用，停止接收新的消息，按选项处理或拒绝pending和还在邮箱中的消息
+func (g *gpcBase) shutdown(pending *data) {
+	// 先关闭让阻塞的发送方返回，才能拿到写锁
+	g.Close()
+	g.sendLocker.Lock()
+	g.closing = true
+	g.sendLocker.Unlock()
+
+	// 因panic停止时不再处理
+	drain := !g.options.rejectOnStop && g.stopReason == nil
+	for d := pending; ; d = nil {
+		if d == nil {
+			if d = g.pop(); d == nil {
+				return
+			}
+		}
+		if drain {
 			select {
-			case d := <-g.ch:
-				g.process(d)
-			case <-g.closeChan:
-				isRun = false
+			case <-g.abortChan:
+				drain = false
+			default:
 			}
 		}
+		if drain && g.stopReason == nil {
+			g.process(d)
+		} else if d.future != nil {
+			d.future.complete(g.closedError(d.method))
+		}
 	}
 }
 
 func (g *gpcBase) process(d *data) {
-	if d.result != nil {
-		// 开启一个计时器
-		if d.startTimerChan != nil {
-			d.startTimerChan <- time.NewTimer(time.Millisecond * GPC_CALL_TIMEOUT)
+	// 调用方已取消或超时，丢弃消息不再执行处理函数
+	if err := d.ctx.Err(); err != nil {
+		if d.future != nil {
+			d.future.complete(callError(d.method, err))
+		}
+		return
+	}
+	// 已经超时的调用
+	if d.future != nil && d.future.isDone() {
+		return
+	}
+	// 已经超时的消息
+	if d.future == nil && !d.deadline.IsZero() && g.clock.Now().After(d.deadline) {
+		return
+	}
+	atomic.AddUint64(&g.processed, 1)
+	g.current = d
+	err := g.handle(d)
+	g.current = nil
+	if d.future != nil {
+		// 延迟回复的处理函数出错时直接回复错误
+		if perr, ok := err.(*PanicError); ok {
+			d.future.complete(callError(d.method, perr))
+		} else if !d.future.deferred || err != nil {
+			d.future.complete(err)
+		}
+	}
+	if perr, ok := err.(*PanicError); ok {
+		g.supervise(perr)
+	}
+}
+
+// 调用处理函数，处理函数panic时返回*PanicError
+func (g *gpcBase) handle(d *data) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = g.recovered(d.method, r)
 		}
+	}()
+	if d.fn != nil {
+		d.fn()
+	} else if d.call != nil {
+		err = d.call(d)
+	} else if d.future != nil {
 		// 處理GPC調用
-		err := g.callMethodFunc(d.method, d.param, d.result)
-		d.errChan <- err
+		err = g.callMethodFunc(d)
 	} else {
-		g.goMethodFunc(d.method, d.param)
+		g.goMethodFunc(d)
 	}
+	return
 }
 
-// 关闭
+// 调用定时器函数，panic时交给监督策略处理
+func (g *gpcBase) tick(tick int32) {
+	if g.tickMethodFunc == nil {
+		return
+	}
+	g.callTick(func() {
+		g.tickMethodFunc(tick)
+	})
+}
+
+func (g *gpcBase) callTick(tickFunc func()) {
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = g.recovered("Tick", r)
+			}
+		}()
+		tickFunc()
+		return
+	}()
+	if err != nil {
+		g.supervise(err.(*PanicError))
+	}
+}
+
+// gpc的名字
+func (g *gpcBase) Name() string {
+	return g.options.name
+}
+
+// 获得gpc基础结构，用于包内对Actor的操作
+func (g *gpcBase) base() *gpcBase {
+	return g
+}
+
+// 创建消息的上下文
+func (g *gpcBase) newContext(d *data) *Context {
+	return &Context{
+		Context: d.ctx,
+		d:       d,
+		self:    g.self,
+	}
+}
+
+// Run退出时调用，通知退出钩子
+func (g *gpcBase) exit() {
+	g.exitLocker.Lock()
+	g.exited = true
+	hooks := g.exitHooks
+	g.exitHooks = nil
+	watchers := g.watchers
+	g.watchers = nil
+	close(g.doneChan)
+	g.exitLocker.Unlock()
+	for _, hook := range hooks {
+		hook(g.stopReason)
+	}
+	g.notifyWatchers(watchers)
+}
+
+// 添加Run退出时调用的钩子，已经退出时立即调用
+func (g *gpcBase) onExit(hook func(reason error)) {
+	g.exitLocker.Lock()
+	if !g.exited {
+		g.exitHooks = append(g.exitHooks, hook)
+		g.exitLocker.Unlock()
+		return
+	}
+	g.exitLocker.Unlock()
+	hook(g.stopReason)
+}
+
+// 关闭，不再接收新的消息，按选项处理或拒绝还在通道中的消息后Run退出，不等待Run退出
 func (g *gpcBase) Close() {
-	close(g.closeChan)
+	g.closeOnce.Do(func() {
+		close(g.closeChan)
+		g.notify()
+	})
+}
+
+// 是否已经调用了Close
+func (g *gpcBase) isClosed() bool {
+	select {
+	case <-g.closeChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// 关闭并等待Run退出，ctx结束时放弃处理剩余的消息并返回ctx的错误，不再等待Run退出
+// Run没有运行或处理函数一直不返回时，Run在之后退出或者不会退出，可以用Done等待
+func (g *gpcBase) Stop(ctx context.Context) error {
+	g.Close()
+	select {
+	case <-g.doneChan:
+		return nil
+	case <-ctx.Done():
+		g.abortOnce.Do(func() {
+			close(g.abortChan)
+		})
+		// Run恰好已经退出时算作成功
+		select {
+		case <-g.doneChan:
+			return nil
+		default:
+		}
+		return ctx.Err()
+	}
+}
+
+// Run退出后关闭的通道
+func (g *gpcBase) Done() <-chan struct{} {
+	return g.doneChan
+}
+
+// Run退出的原因，还在运行或正常关闭时为nil，因panic停止时为*PanicError
+func (g *gpcBase) Err() error {
+	g.exitLocker.Lock()
+	defer g.exitLocker.Unlock()
+	if !g.exited {
+		return nil
+	}
+	return g.stopReason
 }
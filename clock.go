@@ -0,0 +1,183 @@
+package gpc
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// 时钟，gpc的调用超时、定时器函数和定时消息都通过时钟计时
+// 测试时用FakeClock代替实际时间，手动推进时间，不需要真的等待
+type Clock interface {
+	Now() time.Time
+	// d之后调用f
+	AfterFunc(d time.Duration, f func()) ClockTimer
+	// 每隔d调用一次f
+	Every(d time.Duration, f func()) ClockTimer
+	// 每隔d向返回的通道发送一次时间
+	NewTicker(d time.Duration) (<-chan time.Time, ClockTimer)
+}
+
+// 时钟的计时器
+type ClockTimer interface {
+	// 停止计时器，返回计时器是否还没停止
+	Stop() bool
+}
+
+// 实际时间的时钟，由精度为resolution的共用时间轮实现，resolution<=0时使用默认精度
+func RealClock(resolution time.Duration) Clock {
+	return timingWheelOf(resolution)
+}
+
+// 手动推进的时钟，计时器只在Advance中按到期的顺序在调用方协程中执行
+// NewTicker返回的通道没有缓冲，Advance会等待接收方收到每一次发送，定时器函数的调用次数是确定的
+// 所以不能在有定时器函数的gpc的处理函数中调用Advance，也不能在这样的gpc阻塞时调用
+// 使用FakeClock的gpc中，消息的截止时间也按FakeClock计算，上下文本身仍然按实际时间取消
+type FakeClock struct {
+	locker sync.Mutex
+	now    time.Time
+	timers fakeTimers
+	seq    uint64
+}
+
+// FakeClock的计时器
+type fakeTimer struct {
+	c      *FakeClock
+	when   time.Time
+	period time.Duration
+	seq    uint64 // 到期时间相同时按创建的顺序执行
+	fn     func()
+	index  int // 在堆中的位置，不在堆中时为-1
+}
+
+// 按到期时间排序的计时器堆
+type fakeTimers []*fakeTimer
+
+func (h fakeTimers) Len() int { return len(h) }
+func (h fakeTimers) Less(i, j int) bool {
+	if h[i].when.Equal(h[j].when) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].when.Before(h[j].when)
+}
+func (h fakeTimers) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+func (h *fakeTimers) Push(x interface{}) {
+	t := x.(*fakeTimer)
+	t.index = len(*h)
+	*h = append(*h, t)
+}
+func (h *fakeTimers) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	t.index = -1
+	return t
+}
+
+// 创建从start开始的FakeClock
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// 当前时间
+func (c *FakeClock) Now() time.Time {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	return c.now
+}
+
+// 推进时间后d之后调用f
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	return c.schedule(d, 0, f)
+}
+
+// 推进时间后每隔d调用一次f，一次推进跨越多个周期时每个周期都调用
+func (c *FakeClock) Every(d time.Duration, f func()) ClockTimer {
+	if d <= 0 {
+		panic("gpc: non-positive interval for FakeClock.Every")
+	}
+	return c.schedule(d, d, f)
+}
+
+// 每隔d向返回的通道发送一次时间，发送时等待接收方接收或计时器停止
+func (c *FakeClock) NewTicker(d time.Duration) (<-chan time.Time, ClockTimer) {
+	ch := make(chan time.Time)
+	t := &fakeTicker{stopChan: make(chan struct{})}
+	t.timer = c.Every(d, func() {
+		select {
+		case ch <- c.Now():
+		case <-t.stopChan:
+		}
+	})
+	return ch, t
+}
+
+// 推进d，依次执行到期的计时器，执行计时器时Now返回计时器的到期时间
+func (c *FakeClock) Advance(d time.Duration) {
+	c.locker.Lock()
+	target := c.now.Add(d)
+	for len(c.timers) > 0 && !c.timers[0].when.After(target) {
+		t := c.timers[0]
+		c.now = t.when
+		if t.period > 0 {
+			t.when = t.when.Add(t.period)
+			c.seq++
+			t.seq = c.seq
+			heap.Fix(&c.timers, 0)
+		} else {
+			heap.Pop(&c.timers)
+		}
+		c.locker.Unlock()
+		t.fn()
+		c.locker.Lock()
+	}
+	c.now = target
+	c.locker.Unlock()
+}
+
+// 还没到期的计时器数
+func (c *FakeClock) Timers() int {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	return len(c.timers)
+}
+
+func (c *FakeClock) schedule(d, period time.Duration, f func()) *fakeTimer {
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	c.seq++
+	t := &fakeTimer{c: c, when: c.now.Add(d), period: period, seq: c.seq, fn: f}
+	heap.Push(&c.timers, t)
+	return t
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.c
+	c.locker.Lock()
+	defer c.locker.Unlock()
+	if t.index < 0 {
+		return false
+	}
+	heap.Remove(&c.timers, t.index)
+	return true
+}
+
+// FakeClock的Ticker，停止时让等待中的发送返回
+type fakeTicker struct {
+	timer    ClockTimer
+	stopChan chan struct{}
+	stopOnce sync.Once
+}
+
+func (t *fakeTicker) Stop() bool {
+	stopped := t.timer.Stop()
+	t.stopOnce.Do(func() {
+		close(t.stopChan)
+	})
+	return stopped
+}
@@ -0,0 +1,120 @@
+package gpc
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	var ticks, fired int
+	var tickSum int32
+	block := make(chan struct{})
+	handler := NewHandler()
+	handler.RegisterHandle("noop", func(param interface{}, result interface{}) error {
+		return nil
+	})
+	handler.RegisterHandle("fire", func(param interface{}, result interface{}) error {
+		fired++
+		return nil
+	})
+	handler.SetTickHandle("tick", func(tick int32) {
+		ticks++
+		tickSum += tick
+	})
+	g := NewGPCFast(handler, TickMs(10), CallTimeout(100), WithClock(clock))
+	go g.Run()
+	defer g.Close()
+	var r int
+	// 保证Run已经开始
+	if err := g.Call("noop", nil, &r); err != nil {
+		t.Fatal(err)
+	}
+
+	// 定时器函数按推进的时间确定地调用
+	clock.Advance(100 * time.Millisecond)
+	g.Call("noop", nil, &r)
+	if ticks != 10 || tickSum != 100 {
+		t.Fatalf("ticks = %v, sum = %v", ticks, tickSum)
+	}
+
+	// 定时消息
+	g.GoAfter(50*time.Millisecond, "fire", nil)
+	g.Every(20*time.Millisecond, "fire", nil).Stop()
+	clock.Advance(49 * time.Millisecond)
+	g.Call("noop", nil, &r)
+	if fired != 0 {
+		t.Fatalf("fired %v before due", fired)
+	}
+	clock.Advance(time.Millisecond)
+	g.Call("noop", nil, &r)
+	if fired != 1 {
+		t.Fatalf("fired %v after due", fired)
+	}
+
+	// 调用超时，正在处理消息的gpc不能接收Tick，用没有定时器函数的gpc
+	blockHandler := NewHandler()
+	blockHandler.RegisterHandle("block", func(param interface{}, result interface{}) error {
+		<-block
+		return nil
+	})
+	blocker := NewGPCFast(blockHandler, CallTimeout(100), WithClock(clock))
+	go blocker.Run()
+	defer blocker.Close()
+	future := blocker.CallAsync("block", nil, &r)
+	clock.Advance(99 * time.Millisecond)
+	if future.isDone() {
+		t.Fatalf("call timed out early")
+	}
+	clock.Advance(time.Millisecond)
+	if !future.isDone() || !errors.Is(future.Err(), ErrTimeout) {
+		t.Fatalf("call err = %v", future.Err())
+	}
+	close(block)
+}
+
+type ForwardProc struct {
+	peer Actor
+}
+
+func (p *ForwardProc) Forward(c *Context, arg *EchoArgs, reply *EchoReply) error {
+	if err := p.peer.GoContext(c, "record", arg.msg); err != nil {
+		return err
+	}
+	var n int
+	return p.peer.CallContext(c, "record", arg.msg+"!", &n)
+}
+
+func TestFakeClockForward(t *testing.T) {
+	// 转发*Context时沿用消息在模拟时钟上的截止时间
+	clock := NewFakeClock(time.Unix(0, 0))
+	recorded := make(chan string, 2)
+	handler := NewHandler()
+	handler.RegisterHandle("record", func(param interface{}, result interface{}) error {
+		recorded <- param.(string)
+		return nil
+	})
+	peer := NewGPCFast(handler, CallTimeout(100), WithClock(clock))
+	go peer.Run()
+	defer peer.Close()
+	g, err := NewGPC(&ForwardProc{peer: peer}, CallTimeout(100), WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go g.Run()
+	defer g.Close()
+	if err := g.Call("ForwardProc.Forward", &EchoArgs{msg: "hi"}, &EchoReply{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"hi", "hi!"} {
+		select {
+		case got := <-recorded:
+			if got != want {
+				t.Fatalf("recorded %v, want %v", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%v was dropped", want)
+		}
+	}
+}
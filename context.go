@@ -0,0 +1,103 @@
+package gpc
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+// 上下文中保存当前gpc的键
+type actorKey struct{}
+
+// 上下文中保存*Context的键，转发的上下文由此得到消息的截止时间
+type contextKey struct{}
+
+var typeOfContext = reflect.TypeOf((*Context)(nil))
+
+// 消息上下文，处理方法的第一个参数为*Context时传入
+// 内嵌了调用方的context.Context，可以直接传给其他gpc的GoContext/CallContext，对方由此得到发送者
+type Context struct {
+	context.Context
+	d    *data
+	self Actor
+}
+
+// 调用的方法名
+func (c *Context) Method() string {
+	return c.d.method
+}
+
+// 发送者，不是从其他gpc的处理函数中发出的消息返回nil
+func (c *Context) Sender() Actor {
+	return c.d.sender
+}
+
+// 所属的gpc
+func (c *Context) Self() Actor {
+	return c.self
+}
+
+// 消息在所属gpc时钟上的截止时间，取调用方上下文和调用超时中较早的一个
+// 时钟可能是FakeClock，不能作为context.Context的Deadline返回
+func (c *Context) messageDeadline() (time.Time, bool) {
+	return c.d.deadline, !c.d.deadline.IsZero()
+}
+
+// 对actorKey返回所属的gpc，对contextKey返回自己，其他键交给调用方的上下文
+func (c *Context) Value(key interface{}) interface{} {
+	switch key {
+	case actorKey{}:
+		return c.self
+	case contextKey{}:
+		return c
+	}
+	return c.Context.Value(key)
+}
+
+// 以当前消息的上下文向target发起异步调用，结果返回后callback在所属gpc的协程中执行
+// 常与DeferReply配合，在回调中回复调用方
+func (c *Context) Ask(target Actor, methodName string, param interface{}, result interface{}, callback func(err error)) {
+	c.self.base().ask(c, target, methodName, param, result, callback)
+}
+
+// 以所属的gpc为父gpc创建子gpc并运行
+func (c *Context) Spawn(child Actor) error {
+	return c.self.base().Spawn(child)
+}
+
+// 延迟回复，调用后处理函数返回nil时不再回复调用方，由返回的函数在之后回复，只有第一次回复有效
+// Go调用的消息返回的函数什么都不做
+func (c *Context) DeferReply() func(err error) {
+	d := c.d
+	if d.future == nil {
+		return func(error) {}
+	}
+	d.future.deferred = true
+	return func(err error) {
+		d.future.complete(err)
+	}
+}
+
+// 上下文的剩余时间，取ctx的截止时间和转发的消息的截止时间中较早的一个
+// 消息的截止时间按发送方gpc的时钟换算，ctx的截止时间按实际时间换算
+func ctxRemaining(ctx context.Context) (time.Duration, bool) {
+	var remaining time.Duration
+	deadline, ok := ctx.Deadline()
+	if ok {
+		remaining = time.Until(deadline)
+	}
+	if c, o := ctx.Value(contextKey{}).(*Context); o {
+		if deadline, o := c.messageDeadline(); o {
+			if r := deadline.Sub(c.self.base().clock.Now()); !ok || r < remaining {
+				remaining, ok = r, true
+			}
+		}
+	}
+	return remaining, ok
+}
+
+// 从上下文中取出发送者
+func senderFrom(ctx context.Context) Actor {
+	sender, _ := ctx.Value(actorKey{}).(Actor)
+	return sender
+}
@@ -0,0 +1,180 @@
+package gpc
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 死锁检测周期，等待超过一个周期后，被调用的gpc一个周期内没有处理新消息时才检测，获取协程id的开销较大，不在每次调用时检测
+const deadlockCheckDelay = time.Millisecond
+
+// 死锁错误，Chain为形成环的调用链，每一项为 gpc名:方法
+// 第一项是发起调用的gpc正在执行的方法，之后每一项是依次被同步调用的gpc和方法
+type DeadlockError struct {
+	Chain []string
+}
+
+func (e *DeadlockError) Error() string {
+	return "gpc: deadlock detected: " + strings.Join(e.Chain, " -> ")
+}
+
+func (e *DeadlockError) Is(target error) bool {
+	return target == ErrDeadlock
+}
+
+// 等待关系，gpc在执行exec方法时同步调用了to的method方法
+type waitEdge struct {
+	to     *gpcBase
+	exec   string
+	method string
+}
+
+var (
+	deadlockLocker  sync.Mutex
+	actorGoroutines sync.Map // 协程id -> 在该协程中执行的*gpcBase
+)
+
+// 死锁检测的广播时钟，有等待者时每个周期关闭一次通道唤醒所有等待者，避免每次调用都创建计时器
+var probeClock struct {
+	locker  sync.Mutex
+	ch      chan struct{}
+	waiters int
+	running bool
+}
+
+// 等待的第一个检测周期的计时器，复用避免每次等待都分配
+var probeTimers = sync.Pool{
+	New: func() interface{} {
+		t := time.NewTimer(time.Hour)
+		t.Stop()
+		return t
+	},
+}
+
+// 取出一个deadlockCheckDelay后到期的计时器
+func getProbeTimer() *time.Timer {
+	t := probeTimers.Get().(*time.Timer)
+	t.Reset(deadlockCheckDelay)
+	return t
+}
+
+// 放回计时器，fired表示已经从通道中收到到期的时间
+func putProbeTimer(t *time.Timer, fired bool) {
+	if !fired && !t.Stop() {
+		// 已到期但没有收到，取走通道中的值，避免下次使用时立即到期
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+	probeTimers.Put(t)
+}
+
+// 开始等待，返回下一个周期时关闭的通道
+func probeWait() <-chan struct{} {
+	probeClock.locker.Lock()
+	defer probeClock.locker.Unlock()
+	probeClock.waiters++
+	if !probeClock.running {
+		probeClock.running = true
+		probeClock.ch = make(chan struct{})
+		go probeLoop()
+	}
+	return probeClock.ch
+}
+
+// 下一个周期时关闭的通道
+func probeNext() <-chan struct{} {
+	probeClock.locker.Lock()
+	defer probeClock.locker.Unlock()
+	return probeClock.ch
+}
+
+// 结束等待
+func probeDone() {
+	probeClock.locker.Lock()
+	probeClock.waiters--
+	probeClock.locker.Unlock()
+}
+
+func probeLoop() {
+	for {
+		time.Sleep(deadlockCheckDelay)
+		probeClock.locker.Lock()
+		close(probeClock.ch)
+		if probeClock.waiters == 0 {
+			probeClock.running = false
+			probeClock.locker.Unlock()
+			return
+		}
+		probeClock.ch = make(chan struct{})
+		probeClock.locker.Unlock()
+	}
+}
+
+// 当前协程的id
+func goroutineID() int64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	// 格式为 "goroutine 123 [running]:..."
+	s := bytes.TrimPrefix(buf[:n], []byte("goroutine "))
+	if i := bytes.IndexByte(s, ' '); i > 0 {
+		s = s[:i]
+	}
+	id, _ := strconv.ParseInt(string(s), 10, 64)
+	return id
+}
+
+// 当前协程所在的gpc，不在gpc的协程中返回nil
+func currentActor() *gpcBase {
+	v, ok := actorGoroutines.Load(goroutineID())
+	if !ok {
+		return nil
+	}
+	return v.(*gpcBase)
+}
+
+// 开始等待target的method调用，当前协程是gpc协程时记录等待关系，并检查等待关系是否成环
+// 返回当前协程所在的gpc，成环时也返回，等待结束后要调用endWait
+func beginWait(target *gpcBase, method string) (*gpcBase, error) {
+	caller := currentActor()
+	if caller == nil {
+		return nil, nil
+	}
+	var exec string
+	if caller.current != nil {
+		exec = caller.current.method
+	}
+
+	deadlockLocker.Lock()
+	defer deadlockLocker.Unlock()
+
+	chain := []string{caller.Name() + ":" + exec, target.Name() + ":" + method}
+	for t := target; ; {
+		if t == caller {
+			return caller, &DeadlockError{Chain: chain}
+		}
+		w := t.waiting
+		if w == nil {
+			break
+		}
+		chain = append(chain, w.to.Name()+":"+w.method)
+		t = w.to
+	}
+	caller.waiting = &waitEdge{to: target, exec: exec, method: method}
+	return caller, nil
+}
+
+// 结束等待
+func endWait(caller *gpcBase) {
+	if caller == nil {
+		return
+	}
+	deadlockLocker.Lock()
+	caller.waiting = nil
+	deadlockLocker.Unlock()
+}
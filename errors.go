@@ -0,0 +1,63 @@
+package gpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrTimeout         = errors.New("gpc: call timeout")
+	ErrClosed          = errors.New("gpc: closed")
+	ErrMethodNotFound  = errors.New("gpc: can't find method")
+	ErrServiceNotFound = errors.New("gpc: can't find service")
+	ErrBadMethodName   = errors.New("gpc: service/method request ill-formed")
+	ErrMailboxFull     = errors.New("gpc: mailbox full")
+	ErrPanic           = errors.New("gpc: method panic")
+	ErrDeadlock        = errors.New("gpc: deadlock")
+	ErrMaxRestarts     = errors.New("gpc: supervisor reached max restart intensity")
+	ErrBadPath         = errors.New("gpc: bad actor path")
+	ErrNameTaken       = errors.New("gpc: actor path already registered")
+)
+
+// 调用错误，gpc自身产生的调用失败都用它包装，处理函数返回的错误原样返回
+// 可以用errors.Is判断Err，ctx超时的调用也满足errors.Is(err, ErrTimeout)
+type CallError struct {
+	Method string
+	Err    error
+	closed *gpcBase // 消息因为该gpc已关闭而没有处理
+}
+
+func (e *CallError) Error() string {
+	return "gpc: call method (" + e.Method + ") " + strings.TrimPrefix(e.Err.Error(), "gpc: ")
+}
+
+func (e *CallError) Unwrap() error {
+	return e.Err
+}
+
+func (e *CallError) Is(target error) bool {
+	return target == ErrTimeout && errors.Is(e.Err, context.DeadlineExceeded)
+}
+
+// 包装为调用错误，已经是调用错误时不再包装
+func callError(method string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*CallError); ok {
+		return err
+	}
+	return &CallError{Method: method, Err: err}
+}
+
+// g已关闭，消息没有处理就被拒绝
+func (g *gpcBase) closedError(method string) error {
+	return &CallError{Method: method, Err: ErrClosed, closed: g}
+}
+
+// err是否为g拒绝消息时返回的错误，处理函数返回的ErrClosed不算
+func (g *gpcBase) rejected(err error) bool {
+	e, ok := err.(*CallError)
+	return ok && e.closed == g
+}
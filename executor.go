@@ -0,0 +1,205 @@
+package gpc
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	GPC_EXECUTOR_THROUGHPUT = 64 // 默认每次调度最多处理的消息数
+)
+
+// gpc在Executor中的状态
+const (
+	execNew       int32 = iota // 还没有调用Run
+	execIdle                   // 没有消息
+	execScheduled              // 在运行队列中或正在执行
+	execDone                   // 已退出
+)
+
+// 在固定数量的工作协程上运行多个gpc，代替每个gpc一个Run协程
+// 同一个gpc同时只在一个工作协程中执行，消息仍然按顺序处理；每次调度最多处理throughput条消息，之后排到队尾，保证公平
+// 处理函数中的同步调用会占住工作协程，所有工作协程都在等待时会死锁，使用Executor的gpc之间应该用Go或Ask
+type Executor struct {
+	throughput int
+	locker     sync.Mutex
+	cond       *sync.Cond
+	queue      []*gpcBase // 有消息待处理的gpc
+	head       int
+	stopped    bool
+	wg         sync.WaitGroup
+}
+
+type ExecutorOption func(*Executor)
+
+// 每次调度最多处理的消息数
+func Throughput(throughput int) ExecutorOption {
+	return func(e *Executor) {
+		e.throughput = throughput
+	}
+}
+
+// 创建有workers个工作协程的Executor
+func NewExecutor(workers int, options ...ExecutorOption) *Executor {
+	if workers <= 0 {
+		workers = 1
+	}
+	e := &Executor{throughput: GPC_EXECUTOR_THROUGHPUT}
+	for _, option := range options {
+		option(e)
+	}
+	if e.throughput <= 0 {
+		e.throughput = GPC_EXECUTOR_THROUGHPUT
+	}
+	e.cond = sync.NewCond(&e.locker)
+	e.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go e.worker()
+	}
+	return e
+}
+
+// 停止所有工作协程，等待正在执行的gpc处理完本次调度，还在队列中的gpc不再执行
+// 应该先关闭其中的gpc再停止
+func (e *Executor) Stop() {
+	e.locker.Lock()
+	e.stopped = true
+	e.locker.Unlock()
+	e.cond.Broadcast()
+	e.wg.Wait()
+}
+
+// 开始运行gpc，在Run中调用
+func (e *Executor) start(g *gpcBase) {
+	if atomic.CompareAndSwapInt32(&g.execState, execNew, execScheduled) {
+		e.push(g)
+	}
+}
+
+// 放入运行队列
+func (e *Executor) push(g *gpcBase) {
+	e.locker.Lock()
+	e.queue = append(e.queue, g)
+	e.locker.Unlock()
+	e.cond.Signal()
+}
+
+// 取出运行队列中的第一个，停止后返回nil
+func (e *Executor) pop() *gpcBase {
+	e.locker.Lock()
+	defer e.locker.Unlock()
+	for e.head == len(e.queue) && !e.stopped {
+		e.cond.Wait()
+	}
+	if e.stopped {
+		return nil
+	}
+	g := e.queue[e.head]
+	e.queue[e.head] = nil
+	e.head++
+	// 队列空了或者前面空出一半时整理
+	if e.head == len(e.queue) {
+		e.queue = e.queue[:0]
+		e.head = 0
+	} else if e.head > len(e.queue)/2 {
+		n := copy(e.queue, e.queue[e.head:])
+		for i := n; i < len(e.queue); i++ {
+			e.queue[i] = nil
+		}
+		e.queue = e.queue[:n]
+		e.head = 0
+	}
+	return g
+}
+
+func (e *Executor) worker() {
+	defer e.wg.Done()
+	goid := goroutineID()
+	for {
+		g := e.pop()
+		if g == nil {
+			return
+		}
+		// 记录执行的协程，用于检测死锁
+		actorGoroutines.Store(goid, g)
+		e.run(g)
+		actorGoroutines.Delete(goid)
+	}
+}
+
+// 处理gpc的一批消息
+func (e *Executor) run(g *gpcBase) {
+	if !g.started {
+		g.started = true
+		g.startFailed = !g.begin()
+		if !g.startFailed && g.hasTick() {
+			g.startTicker()
+		}
+	}
+	var pending *data
+	for i := 0; i < e.throughput && !g.stopped; i++ {
+		d := g.pop()
+		if d == nil {
+			break
+		}
+		// 已经关闭时交给shutdown按选项处理
+		select {
+		case <-g.closeChan:
+			g.stopped = true
+			pending = d
+		default:
+			g.process(d)
+		}
+	}
+	if !g.stopped && g.isClosed() {
+		g.stopped = true
+	}
+	if g.stopped {
+		atomic.StoreInt32(&g.execState, execDone)
+		if g.ticker != nil {
+			g.ticker.Stop()
+		}
+		if g.hasChildren() {
+			// 等待子gpc退出会占住工作协程，子gpc可能也在这个Executor中
+			go func() {
+				g.end(pending, !g.startFailed)
+				g.exit()
+			}()
+			return
+		}
+		g.end(pending, !g.startFailed)
+		g.exit()
+		return
+	}
+	if g.queued() > 0 {
+		e.push(g)
+		return
+	}
+	atomic.StoreInt32(&g.execState, execIdle)
+	// 设置为空闲之前放入的消息和关闭没有通知
+	if g.queued() > 0 || g.isClosed() {
+		g.notify()
+	}
+}
+
+// 有新消息或者关闭时通知Executor
+func (g *gpcBase) notify() {
+	if e := g.options.executor; e != nil && atomic.CompareAndSwapInt32(&g.execState, execIdle, execScheduled) {
+		e.push(g)
+	}
+}
+
+// 按tickMs把调用定时器函数的消息投递到通道，上一次还没执行时跳过
+func (g *gpcBase) startTicker() {
+	var pending int32
+	g.ticker = g.clock.Every(time.Duration(g.options.tickMs)*time.Millisecond, func() {
+		if !atomic.CompareAndSwapInt32(&pending, 0, 1) {
+			return
+		}
+		g.post(func() {
+			atomic.StoreInt32(&pending, 0)
+			g.onTick(g.clock.Now())
+		})
+	})
+}
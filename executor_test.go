@@ -0,0 +1,121 @@
+package gpc
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newSerialHandler(t *testing.T) *Handler {
+	var running int32
+	count := 0
+	handler := NewHandler()
+	handler.RegisterHandle("incr", func(param interface{}, result interface{}) error {
+		// 同一个gpc不能同时在两个工作协程中执行
+		if !atomic.CompareAndSwapInt32(&running, 0, 1) {
+			t.Errorf("concurrent execution")
+		}
+		count++
+		atomic.StoreInt32(&running, 0)
+		return nil
+	})
+	handler.RegisterHandle("get", func(param interface{}, result interface{}) error {
+		*(result.(*int)) = count
+		return nil
+	})
+	return handler
+}
+
+func TestExecutor(t *testing.T) {
+	exec := NewExecutor(4)
+	defer exec.Stop()
+	var actors []*GPCFast
+	for i := 0; i < 200; i++ {
+		g := NewGPCFast(newSerialHandler(t), RunOn(exec))
+		g.Run()
+		actors = append(actors, g)
+	}
+	var wg sync.WaitGroup
+	for p := 0; p < 4; p++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				for _, g := range actors {
+					g.Go("incr", nil)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for _, g := range actors {
+		var n int
+		if err := g.Call("get", nil, &n); err != nil || n != 200 {
+			t.Fatalf("count = %v, err = %v", n, err)
+		}
+	}
+	for _, g := range actors {
+		if err := g.Stop(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestExecutorFairness(t *testing.T) {
+	exec := NewExecutor(1, Throughput(10))
+	defer exec.Stop()
+	handler := NewHandler()
+	handler.RegisterHandle("slow", func(param interface{}, result interface{}) error {
+		time.Sleep(100 * time.Microsecond)
+		return nil
+	})
+	busy := NewGPCFast(handler, RunOn(exec), ChannelLen(10000))
+	busy.Run()
+	idle := NewGPCFast(newSerialHandler(t), RunOn(exec))
+	idle.Run()
+	for i := 0; i < 5000; i++ {
+		busy.Go("slow", nil)
+	}
+	var n int
+	if err := idle.Call("get", nil, &n); err != nil {
+		t.Fatal(err)
+	}
+	// 繁忙的gpc每次只处理throughput条，其他gpc不会等它处理完
+	if left := busy.mailbox.Len(); left < 4000 {
+		t.Fatalf("idle actor waited for %v messages of busy actor", 5000-left)
+	}
+	busy.Stop(context.Background())
+	idle.Stop(context.Background())
+}
+
+func TestExecutorTickAndLifecycle(t *testing.T) {
+	exec := NewExecutor(2)
+	defer exec.Stop()
+	events := make(chan string, 10)
+	var ticks int32
+	handler := newCounterHandler()
+	handler.SetTickHandle("tick", func(tick int32) {
+		atomic.AddInt32(&ticks, 1)
+	})
+	handler.SetStartHandle(func(ctx context.Context) error {
+		events <- "start"
+		return nil
+	})
+	handler.SetStopHandle(func(reason error) {
+		events <- "stop"
+	})
+	g := NewGPCFast(handler, RunOn(exec), TickMs(5))
+	g.Run()
+	if e := <-events; e != "start" {
+		t.Fatalf("event %v", e)
+	}
+	for atomic.LoadInt32(&ticks) < 3 {
+		time.Sleep(time.Millisecond)
+	}
+	g.Stop(context.Background())
+	if e := <-events; e != "stop" {
+		t.Fatalf("event %v", e)
+	}
+}
@@ -0,0 +1,65 @@
+package gpc
+
+import (
+	"time"
+)
+
+const (
+	GPC_MAX_CATCH_UP = 5 // 固定步长模式下默认一次最多追赶的帧数
+)
+
+// 固定步长模式的服务接口，frame从1开始递增，dt固定为tickMs
+// 服务同时实现了Tick时只调用FrameTick，只实现Tick时以固定的tickMs调用Tick
+type FrameService interface {
+	FrameTick(frame int64, dt int32)
+}
+
+// 固定步长模式下一帧的处理时间超过tickMs时调用，在Run所在协程中执行
+type TickOverrunHook func(frame int64, cost time.Duration)
+
+// 固定步长模式的帧计时
+type frameClock struct {
+	start time.Time // 第0帧的时间，放弃落后的帧时向后移动
+	frame int64     // 已执行的帧数
+}
+
+// 服务的固定步长定时器函数，没有实现FrameService接口时返回nil
+func serviceFrameTick(serv interface{}) func(frame int64, dt int32) {
+	if frameServ, ok := serv.(FrameService); ok {
+		return frameServ.FrameTick
+	}
+	return nil
+}
+
+// 执行到now为止应该执行的帧
+func (g *gpcBase) tickFrames(fc *frameClock, now time.Time) {
+	step := time.Duration(g.options.tickMs) * time.Millisecond
+	maxCatchUp := int64(g.options.maxCatchUp)
+	if maxCatchUp <= 0 {
+		maxCatchUp = GPC_MAX_CATCH_UP
+	}
+	due := int64(now.Sub(fc.start)/step) - fc.frame
+	if due > maxCatchUp {
+		fc.start = fc.start.Add(time.Duration(due-maxCatchUp) * step)
+		due = maxCatchUp
+	}
+	for ; due > 0 && !g.stopped; due-- {
+		fc.frame++
+		begin := g.clock.Now()
+		g.tickFrame(fc.frame, g.options.tickMs)
+		if cost := g.clock.Now().Sub(begin); cost > step && g.options.tickOverrunHook != nil {
+			g.options.tickOverrunHook(fc.frame, cost)
+		}
+	}
+}
+
+// 执行一帧
+func (g *gpcBase) tickFrame(frame int64, dt int32) {
+	if g.frameMethodFunc != nil {
+		g.callTick(func() {
+			g.frameMethodFunc(frame, dt)
+		})
+	} else {
+		g.tick(dt)
+	}
+}
@@ -0,0 +1,202 @@
+package gpc
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 异步调用的结果
+type Future struct {
+	target    *gpcBase // 被调用的gpc，用于检测死锁
+	method    string
+	done      chan struct{}
+	err       error
+	locker    sync.Mutex
+	completed bool
+	callbacks []func(err error)
+	timer     ClockTimer  // 超时计时器
+	result    interface{} // 处理函数的回复参数
+	deferred  bool        // 处理函数调用了Context.DeferReply，只在被调用的gpc协程中读写
+}
+
+// 创建Future
+func newFuture(target *gpcBase, method string) *Future {
+	return &Future{
+		target: target,
+		method: method,
+		done:   make(chan struct{}),
+	}
+}
+
+// 等待调用完成，返回调用的错误
+func (f *Future) Wait() error {
+	return f.wait(context.Background())
+}
+
+// 等待调用完成或ctx结束，等待超过deadlockCheckDelay后检测死锁
+func (f *Future) wait(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return f.err
+	default:
+	}
+	if f.target != nil {
+		// 大多数调用在一个检测周期内完成，不进入检测
+		timer := getProbeTimer()
+		select {
+		case <-f.done:
+			putProbeTimer(timer, false)
+			return f.err
+		case <-ctx.Done():
+			putProbeTimer(timer, false)
+			// 让还在排队的消息被丢弃
+			f.complete(callError(f.method, ctx.Err()))
+			<-f.done
+			return f.err
+		case <-f.target.doneChan:
+			putProbeTimer(timer, false)
+			f.complete(callError(f.method, ErrClosed))
+			<-f.done
+			return f.err
+		case <-timer.C:
+			putProbeTimer(timer, true)
+		}
+		// 被调用的gpc一直在处理新消息时不可能死锁，一个检测周期内卡在同一条消息上时才检测
+		probe := probeWait()
+		processed := atomic.LoadUint64(&f.target.processed)
+		for stalled := false; !stalled; {
+			select {
+			case <-f.done:
+				probeDone()
+				return f.err
+			case <-ctx.Done():
+				probeDone()
+				f.complete(callError(f.method, ctx.Err()))
+				<-f.done
+				return f.err
+			case <-f.target.doneChan:
+				probeDone()
+				f.complete(callError(f.method, ErrClosed))
+				<-f.done
+				return f.err
+			case <-probe:
+			}
+			n := atomic.LoadUint64(&f.target.processed)
+			stalled = n == processed
+			processed = n
+			probe = probeNext()
+		}
+		probeDone()
+		caller, err := beginWait(f.target, f.method)
+		if err != nil {
+			f.complete(callError(f.method, err))
+			if caller != nil && caller.options.panicOnDeadlock {
+				panic(err)
+			}
+		}
+		defer endWait(caller)
+	}
+	var closed <-chan struct{}
+	if f.target != nil {
+		closed = f.target.doneChan
+	}
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		f.complete(callError(f.method, ctx.Err()))
+		<-f.done
+	case <-closed:
+		f.complete(callError(f.method, ErrClosed))
+		<-f.done
+	}
+	return f.err
+}
+
+// 调用完成时关闭的通道，可用于select
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// 调用的错误，未完成时返回nil
+func (f *Future) Err() error {
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	return f.err
+}
+
+// 注册完成时的回调，已完成时立即调用
+// 回调在完成Future的协程中执行，可能是处理调用的gpc协程、等待结果的协程或超时时新建的协程，不要长时间阻塞
+// 要在某个gpc的Run所在协程中处理结果时用PipeTo
+func (f *Future) Then(callback func(err error)) *Future {
+	f.locker.Lock()
+	if !f.completed {
+		f.callbacks = append(f.callbacks, callback)
+		f.locker.Unlock()
+		return f
+	}
+	err := f.err
+	f.locker.Unlock()
+	callback(err)
+	return f
+}
+
+// 完成时把callback作为消息投递到target的通道，在target的Run所在协程中执行
+func (f *Future) PipeTo(target Actor, callback func(err error)) {
+	t := target.base()
+	f.Then(func(err error) {
+		t.post(func() {
+			callback(err)
+		})
+	})
+}
+
+// 设置结果，只有第一次有效，返回是否设置成功
+func (f *Future) complete(err error) bool {
+	f.locker.Lock()
+	if f.completed {
+		f.locker.Unlock()
+		return false
+	}
+	f.completed = true
+	f.err = err
+	callbacks := f.callbacks
+	f.callbacks = nil
+	if f.timer != nil {
+		f.timer.Stop()
+	}
+	close(f.done)
+	f.locker.Unlock()
+	for _, callback := range callbacks {
+		callback(err)
+	}
+	return true
+}
+
+// 设置超时，超时后以err完成
+func (f *Future) setTimeout(timeout time.Duration, err error) {
+	f.locker.Lock()
+	defer f.locker.Unlock()
+	if f.completed {
+		return
+	}
+	f.timer = f.target.clock.AfterFunc(timeout, func() {
+		// 实际时间的时间轮被所有gpc共用，回调阻塞时会推迟其他的计时器，在新的协程中完成
+		if _, ok := f.target.clock.(*timingWheel); ok {
+			go f.complete(err)
+			return
+		}
+		f.complete(err)
+	})
+}
+
+// 是否已完成
+func (f *Future) isDone() bool {
+	select {
+	case <-f.done:
+		return true
+	default:
+		return false
+	}
+}
@@ -1,3 +1,3 @@
 module github.com/huoshan017/gpc
 
-go 1.16
+go 1.18
@@ -13,9 +13,10 @@ import (
 
 // 方法反射信息结构
 type methodType struct {
-	method    reflect.Method
-	ArgType   reflect.Type
-	ReplyType reflect.Type
+	method      reflect.Method
+	ArgType     reflect.Type
+	ReplyType   reflect.Type
+	withContext bool // 第一个参数是*Context
 }
 
 // gpc服务
@@ -40,30 +41,53 @@ type GPC struct {
 
 // 创建一个gpc
 func NewGPC(serv interface{}, options ...GPCOption) (*GPC, error) {
-	gpc := &GPC{serviceMap: make(map[string]*service)}
+	gpc := &GPC{serv: serv, serviceMap: make(map[string]*service)}
 	for _, option := range options {
-		option(gpc.options)
+		option(&gpc.options)
 	}
-	tickServ, ok := serv.(Service)
-	if !ok {
-		tickServ = nil
-	}
-	gpc.init(gpc.callMethod, gpc.postMethod, func() func(tick int32) {
-		if tickServ != nil {
-			return tickServ.Tick
-		} else {
-			return nil
-		}
-	}())
+	gpc.init(gpc, gpc.callMethod, gpc.postMethod, serviceTick(serv))
+	gpc.frameMethodFunc = serviceFrameTick(serv)
+	gpc.restartFunc = gpc.restart
+	gpc.setServiceHooks(serv)
 	err := gpc.register(serv)
+	if gpc.options.name == "" {
+		gpc.options.name = reflect.Indirect(reflect.ValueOf(serv)).Type().Name()
+	}
 	return gpc, err
 }
 
+// 服务的定时器函数，没有实现Service接口时返回nil
+func serviceTick(serv interface{}) func(tick int32) {
+	if tickServ, ok := serv.(Service); ok {
+		return tickServ.Tick
+	}
+	return nil
+}
+
 // 获得服务
 func (g *GPC) GetServ() interface{} {
 	return g.serv
 }
 
+// 用工厂重新创建服务，在Run所在协程中调用
+func (g *GPC) restart() error {
+	serv, err := g.options.factory()
+	if err != nil {
+		return err
+	}
+	serviceMap := g.serviceMap
+	g.serviceMap = make(map[string]*service)
+	if err = g.register(serv); err != nil {
+		g.serviceMap = serviceMap
+		return err
+	}
+	g.serv = serv
+	g.tickMethodFunc = serviceTick(serv)
+	g.frameMethodFunc = serviceFrameTick(serv)
+	g.setServiceHooks(serv)
+	return nil
+}
+
 // 注册一个gpc服务
 func (g *GPC) register(rcvr interface{}) error {
 	s := &service{}
@@ -99,28 +123,38 @@ func (g *GPC) register(rcvr interface{}) error {
 }
 
 // Run中调用的处理函数，因为go无法支持在一个类型中的方法中调用接口达到虚函数的效果
-func (g *GPC) callMethod(method string, param interface{}, result interface{}) error {
-	service, mtype, err := g.getMethod(method)
-	function := mtype.method.Func
-	returnValues := function.Call([]reflect.Value{service.rcvr, reflect.ValueOf(param), reflect.ValueOf(result)})
-	errInter := returnValues[0].Interface()
-	if errInter != nil {
-		err = errInter.(error)
+func (g *GPC) callMethod(d *data) error {
+	return g.invoke(d, d.future.result)
+}
+
+func (g *GPC) postMethod(d *data) {
+	err := g.invoke(d, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stdout, "gpc: post method %v err: %v", d.method, err)
 	}
-	return err
 }
 
-func (g *GPC) postMethod(method string, param interface{}) {
-	service, mtype, err := g.getMethod(method)
-	function := mtype.method.Func
-	returnValues := function.Call([]reflect.Value{service.rcvr, reflect.ValueOf(param)})
+// 通过反射调用服务方法，result为nil时不传回复参数
+func (g *GPC) invoke(d *data, result interface{}) error {
+	service, mtype, err := g.getMethod(d.method)
+	if err != nil {
+		return err
+	}
+	in := make([]reflect.Value, 0, 4)
+	in = append(in, service.rcvr)
+	if mtype.withContext {
+		in = append(in, reflect.ValueOf(g.newContext(d)))
+	}
+	in = append(in, reflect.ValueOf(d.param))
+	if result != nil {
+		in = append(in, reflect.ValueOf(result))
+	}
+	returnValues := mtype.method.Func.Call(in)
 	errInter := returnValues[0].Interface()
 	if errInter != nil {
 		err = errInter.(error)
 	}
-	if err != nil {
-		fmt.Fprintf(os.Stdout, "gpc: post method %v err: %v", method, err)
-	}
+	return err
 }
 
 // 内部方法，是否已导出
@@ -149,18 +183,24 @@ func suitableMethods(typ reflect.Type, reportErr bool) map[string]*methodType {
 			continue
 		}
 		// 定时器函数跳过
-		if mname == "Tick" {
+		if isLifecycleMethod(mname) {
 			continue
 		}
+		// 接收者之后的第一个参数可以是*Context，不计入参数个数
+		argIndex := 1
+		if mtype.NumIn() > 1 && mtype.In(1) == typeOfContext {
+			argIndex = 2
+		}
+		numIn := mtype.NumIn() - argIndex + 1
 		// 方法需要三个传入参数： 接收者，参数，回复
-		if method.Type.NumIn() != 3 && method.Type.NumIn() != 2 {
+		if numIn != 3 && numIn != 2 {
 			if reportErr {
-				log.Printf("gpc.Register: method %q has %d input parameters; need exactly three or two\n", mname, mtype.NumIn())
+				log.Printf("gpc.Register: method %q has %d input parameters; need exactly three or two\n", mname, numIn)
 			}
 			continue
 		}
 		// 第一个参数类型不能是指针
-		argType := mtype.In(1)
+		argType := mtype.In(argIndex)
 		if !isExportedOrBuiltinType(argType) {
 			if reportErr {
 				log.Printf("gpc.Register: argument type of method %q is not exported: %q\n", mname, argType)
@@ -168,9 +208,9 @@ func suitableMethods(typ reflect.Type, reportErr bool) map[string]*methodType {
 			continue
 		}
 		var replyType reflect.Type
-		if method.Type.NumIn() == 3 {
+		if numIn == 3 {
 			// 第二个参数必须是指针
-			replyType = mtype.In(2)
+			replyType = mtype.In(argIndex + 1)
 			if replyType.Kind() != reflect.Ptr {
 				if reportErr {
 					log.Printf("gpc.Register: reply type of method %q is not a pointer: %q\n", mname, replyType)
@@ -199,7 +239,7 @@ func suitableMethods(typ reflect.Type, reportErr bool) map[string]*methodType {
 			}
 			continue
 		}
-		methods[mname] = &methodType{method: method, ArgType: argType, ReplyType: replyType}
+		methods[mname] = &methodType{method: method, ArgType: argType, ReplyType: replyType, withContext: argIndex == 2}
 	}
 	return methods
 }
@@ -208,7 +248,7 @@ func suitableMethods(typ reflect.Type, reportErr bool) map[string]*methodType {
 func (g *GPC) getMethod(method string) (svc *service, mtype *methodType, err error) {
 	dot := strings.LastIndex(method, ".")
 	if dot < 0 {
-		err = errors.New("gpc: service/method request ill-formed: " + method)
+		err = callError(method, ErrBadMethodName)
 		return
 	}
 	serviceName := method[:dot]
@@ -216,13 +256,13 @@ func (g *GPC) getMethod(method string) (svc *service, mtype *methodType, err err
 
 	svc, o := g.serviceMap[serviceName]
 	if !o {
-		err = errors.New("gpc: can't find service " + method)
+		err = callError(method, ErrServiceNotFound)
 		return
 	}
 
 	mtype = svc.method[methodName]
 	if mtype == nil {
-		err = errors.New("gpc: can't find method " + method)
+		err = callError(method, ErrMethodNotFound)
 		return
 	}
 	return
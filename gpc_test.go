@@ -1,9 +1,16 @@
 package gpc
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 type friend struct {
@@ -174,7 +181,7 @@ func (f *FriendManagerProc) Tick(tick int32) {
 
 func TestFriend2(t *testing.T) {
 	idLength := 10000000
-	gpcFriend, err := NewGPC(newFriendManagerProc(), ChannelLen(idLength))
+	gpcFriend, err := NewGPC(newFriendManagerProc(), ChannelLen(idLength), NoCallTimeout())
 	if err != nil {
 		t.Error(err)
 		return
@@ -238,3 +245,853 @@ func TestFriend2(t *testing.T) {
 	}()
 	wg.Wait()
 }
+
+func TestCallContext(t *testing.T) {
+	block := make(chan struct{})
+	var count int
+	handler := NewHandler()
+	handler.RegisterHandle("block", func(param interface{}, result interface{}) error {
+		<-block
+		return nil
+	})
+	handler.RegisterHandle("count", func(param interface{}, result interface{}) error {
+		count++
+		return nil
+	})
+	handler.RegisterHandle("get", func(param interface{}, result interface{}) error {
+		*(result.(*int)) = count
+		return nil
+	})
+	g := NewGPCFast(handler)
+	defer g.Close()
+	go g.Run()
+
+	g.Go("block", nil)
+
+	// 排队中超时的调用要返回，并且不再执行
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := g.CallContext(ctx, "count", nil, &struct{}{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("CallContext err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	<-ctx.Done()
+	if err := g.GoContext(ctx, "count", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GoContext err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	close(block)
+
+	var n int
+	if err := g.CallContext(context.Background(), "get", nil, &n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("expired message was handled %v times", n)
+	}
+}
+
+type EchoProc struct {
+	peer   Actor
+	sender Actor
+}
+
+type EchoArgs struct {
+	msg string
+}
+
+type EchoReply struct {
+	msg string
+}
+
+func (e *EchoProc) Echo(c *Context, arg *EchoArgs, reply *EchoReply) error {
+	if _, ok := c.messageDeadline(); !ok {
+		return fmt.Errorf("no deadline for %v", c.Method())
+	}
+	e.sender = c.Sender()
+	reply.msg = c.Method() + ":" + arg.msg
+	return nil
+}
+
+func (e *EchoProc) Forward(c *Context, arg *EchoArgs, reply *EchoReply) error {
+	return e.peer.CallContext(c, "EchoProc.Echo", arg, reply)
+}
+
+func (e *EchoProc) Later(c *Context, arg *EchoArgs, reply *EchoReply) error {
+	done := c.DeferReply()
+	go func() {
+		reply.msg = arg.msg
+		done(nil)
+	}()
+	return nil
+}
+
+func TestContextMethod(t *testing.T) {
+	a, err := NewGPC(&EchoProc{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	go a.Run()
+	b, err := NewGPC(&EchoProc{peer: a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	go b.Run()
+
+	reply := &EchoReply{}
+	if err = b.Call("EchoProc.Forward", &EchoArgs{msg: "hi"}, reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.msg != "EchoProc.Echo:hi" {
+		t.Errorf("reply = %q", reply.msg)
+	}
+	if sender := a.GetServ().(*EchoProc).sender; sender != Actor(b) {
+		t.Errorf("sender = %v, want %v", sender, b)
+	}
+
+	if err = a.Call("EchoProc.Later", &EchoArgs{msg: "later"}, reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.msg != "later" {
+		t.Errorf("deferred reply = %q", reply.msg)
+	}
+}
+
+func TestCallAsync(t *testing.T) {
+	block := make(chan struct{})
+	handler := NewHandler()
+	handler.RegisterHandle("double", func(param interface{}, result interface{}) error {
+		*(result.(*int)) = param.(int) * 2
+		return nil
+	})
+	handler.RegisterHandle("block", func(param interface{}, result interface{}) error {
+		<-block
+		return nil
+	})
+	g := NewGPCFast(handler, CallTimeout(20))
+	defer g.Close()
+	go g.Run()
+
+	results := make([]int, 10)
+	futures := make([]*Future, len(results))
+	var thenCount int32
+	for i := range futures {
+		futures[i] = g.CallAsync("double", i, &results[i]).Then(func(err error) {
+			atomic.AddInt32(&thenCount, 1)
+		})
+	}
+	for i, f := range futures {
+		if err := f.Wait(); err != nil {
+			t.Fatal(err)
+		}
+		if results[i] != i*2 {
+			t.Errorf("results[%v] = %v", i, results[i])
+		}
+	}
+	if n := atomic.LoadInt32(&thenCount); n != int32(len(futures)) {
+		t.Errorf("Then called %v times", n)
+	}
+
+	f := g.CallAsync("block", nil, &struct{}{})
+	select {
+	case <-f.Done():
+	case <-time.After(time.Second):
+		t.Fatal("call did not time out")
+	}
+	if f.Err() == nil {
+		t.Error("want timeout error")
+	}
+
+	// 超时时回调阻塞不影响其他gpc的计时器
+	fired := make(chan struct{})
+	other := NewHandler()
+	other.RegisterHandle("fire", func(param interface{}, result interface{}) error {
+		close(fired)
+		return nil
+	})
+	o := NewGPCFast(other)
+	go o.Run()
+	defer o.Close()
+	// 时间轮中一直有计时器，推进的协程不会退出
+	defer o.GoAfter(time.Hour, "fire", nil).Stop()
+	hold := make(chan struct{})
+	timedOut := make(chan struct{})
+	g.CallAsync("block", nil, &struct{}{}).Then(func(err error) {
+		close(timedOut)
+		<-hold
+	})
+	<-timedOut
+	o.GoAfter(5*time.Millisecond, "fire", nil)
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Error("timer blocked by a Then callback")
+	}
+	close(hold)
+	close(block)
+}
+
+type PingProc struct {
+	id    int
+	peer  Actor
+	count int
+}
+
+type PingArgs struct {
+}
+
+type PingReply struct {
+	id int
+}
+
+func (p *PingProc) Ping(c *Context, arg *PingArgs, reply *PingReply) error {
+	done := c.DeferReply()
+	pong := &PingReply{}
+	c.Ask(p.peer, "PingProc.Pong", arg, pong, func(err error) {
+		p.count++
+		reply.id = pong.id
+		done(err)
+	})
+	return nil
+}
+
+func (p *PingProc) Pong(arg *PingArgs, reply *PingReply) error {
+	reply.id = p.id
+	return nil
+}
+
+func TestAsk(t *testing.T) {
+	pa, pb := &PingProc{id: 1}, &PingProc{id: 2}
+	a, _ := NewGPC(pa)
+	b, _ := NewGPC(pb)
+	pa.peer, pb.peer = b, a
+	defer a.Close()
+	defer b.Close()
+	go a.Run()
+	go b.Run()
+
+	// 两个gpc在处理函数中互相调用，同步调用会死锁
+	n := 100
+	wg := &sync.WaitGroup{}
+	for _, g := range []*GPC{a, b} {
+		wg.Add(1)
+		go func(g *GPC, want int) {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				reply := &PingReply{}
+				if err := g.Call("PingProc.Ping", &PingArgs{}, reply); err != nil {
+					t.Error(err)
+					return
+				}
+				if reply.id != want {
+					t.Errorf("pong from %v, want %v", reply.id, want)
+				}
+			}
+		}(g, 3-g.GetServ().(*PingProc).id)
+	}
+	wg.Wait()
+
+	// 回调和处理函数在同一个协程中执行
+	if err := a.Call("PingProc.Ping", &PingArgs{}, &PingReply{}); err != nil {
+		t.Fatal(err)
+	}
+	if pa.count != n+1 {
+		t.Errorf("continuations run %v times, want %v", pa.count, n+1)
+	}
+}
+
+type CycleProc struct {
+	peer *GPC
+}
+
+type CycleArgs struct {
+	depth int
+}
+
+type CycleReply struct {
+}
+
+func (p *CycleProc) Self(arg *CycleArgs, reply *CycleReply) error {
+	return p.peer.Call("CycleProc.Leaf", arg, reply)
+}
+
+func (p *CycleProc) Forward(arg *CycleArgs, reply *CycleReply) error {
+	return p.peer.Call("CycleProc.Back", arg, reply)
+}
+
+func (p *CycleProc) Back(arg *CycleArgs, reply *CycleReply) error {
+	return p.peer.Call("CycleProc.Leaf", arg, reply)
+}
+
+func (p *CycleProc) Leaf(arg *CycleArgs, reply *CycleReply) error {
+	return nil
+}
+
+func TestDeadlock(t *testing.T) {
+	pa, pb := &CycleProc{}, &CycleProc{}
+	a, _ := NewGPC(pa, Name("a"), NoCallTimeout())
+	b, _ := NewGPC(pb, Name("b"), NoCallTimeout())
+	defer a.Close()
+	defer b.Close()
+	go a.Run()
+	go b.Run()
+
+	// 调用自己
+	pa.peer = a
+	err := a.Call("CycleProc.Self", &CycleArgs{}, &CycleReply{})
+	var deadlock *DeadlockError
+	if !errors.As(err, &deadlock) {
+		t.Fatalf("self call err = %v, want DeadlockError", err)
+	}
+	if chain := strings.Join(deadlock.Chain, " -> "); chain != "a:CycleProc.Self -> a:CycleProc.Leaf" {
+		t.Errorf("chain = %v", chain)
+	}
+
+	// a -> b -> a
+	pa.peer, pb.peer = b, a
+	err = a.Call("CycleProc.Forward", &CycleArgs{}, &CycleReply{})
+	if !errors.Is(err, ErrDeadlock) {
+		t.Fatalf("cyclic call err = %v, want ErrDeadlock", err)
+	}
+	// 环中任何一个gpc都可能先检测到死锁
+	if !errors.As(err, &deadlock) {
+		t.Fatalf("cyclic call err = %v, want DeadlockError", err)
+	}
+	switch chain := strings.Join(deadlock.Chain, " -> "); chain {
+	case "a:CycleProc.Forward -> b:CycleProc.Back -> a:CycleProc.Leaf":
+	case "b:CycleProc.Back -> a:CycleProc.Leaf -> b:CycleProc.Back":
+	default:
+		t.Errorf("chain = %v", chain)
+	}
+}
+
+func TestPanicOnDeadlock(t *testing.T) {
+	// 死锁的panic会让进程退出，在子进程中运行
+	if os.Getenv("GPC_TEST_PANIC_ON_DEADLOCK") == "1" {
+		pa := &CycleProc{}
+		a, _ := NewGPC(pa, Name("a"), NoCallTimeout(), PanicOnDeadlock())
+		pa.peer = a
+		go a.Run()
+		a.Call("CycleProc.Self", &CycleArgs{}, &CycleReply{})
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestPanicOnDeadlock$")
+	cmd.Env = append(os.Environ(), "GPC_TEST_PANIC_ON_DEADLOCK=1")
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("err = %v, want the process to panic\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "panic: gpc: deadlock detected: a:CycleProc.Self -> a:CycleProc.Leaf") {
+		t.Fatalf("output does not contain the deadlock panic\n%s", out)
+	}
+}
+
+func newCounterHandler() *Handler {
+	var count int
+	handler := NewHandler()
+	handler.RegisterHandle("incr", func(param interface{}, result interface{}) error {
+		count++
+		*(result.(*int)) = count
+		return nil
+	})
+	handler.RegisterHandle("boom", func(param interface{}, result interface{}) error {
+		panic("boom")
+	})
+	return handler
+}
+
+func TestPanicSupervise(t *testing.T) {
+	var directives []Directive
+	g := NewGPCFast(newCounterHandler(),
+		Supervise(func(err *PanicError) Directive {
+			if len(directives) == 0 {
+				return Restart
+			}
+			return Stop
+		}),
+		OnSupervise(func(directive Directive, err *PanicError) {
+			directives = append(directives, directive)
+		}),
+		Factory(func() (interface{}, error) {
+			return newCounterHandler(), nil
+		}))
+	runDone := make(chan struct{})
+	go func() {
+		g.Run()
+		close(runDone)
+	}()
+
+	var n int
+	for i := 0; i < 2; i++ {
+		if err := g.Call("incr", nil, &n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := g.Call("boom", nil, &n)
+	var perr *PanicError
+	if !errors.As(err, &perr) || perr.Value != "boom" || len(perr.Stack) == 0 {
+		t.Fatalf("err = %v, want PanicError", err)
+	}
+	// 重新创建后状态重置
+	if err = g.Call("incr", nil, &n); err != nil || n != 1 {
+		t.Fatalf("after restart n = %v, err = %v", n, err)
+	}
+	g.Call("boom", nil, &n)
+	select {
+	case <-runDone:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not stop")
+	}
+	if len(directives) != 2 || directives[0] != Restart || directives[1] != Stop {
+		t.Errorf("directives = %v", directives)
+	}
+}
+
+func TestPanicErrorUnwrap(t *testing.T) {
+	errBoom := errors.New("boom")
+	handler := NewHandler()
+	handler.RegisterHandle("boom", func(param interface{}, result interface{}) error {
+		panic(errBoom)
+	})
+	g := NewGPCFast(handler)
+	go g.Run()
+	defer g.Close()
+	var n int
+	err := g.Call("boom", nil, &n)
+	if !errors.Is(err, ErrPanic) || !errors.Is(err, errBoom) {
+		t.Fatalf("err = %v, want ErrPanic wrapping the panic value", err)
+	}
+}
+
+type LifeProc struct {
+	loaded   bool
+	events   chan string
+	startErr error
+}
+
+type LifeArgs struct {
+}
+
+func (l *LifeProc) OnStart(ctx context.Context) error {
+	if ctx.Value(actorKey{}) == nil {
+		return errors.New("no self in OnStart context")
+	}
+	l.loaded = true
+	return l.startErr
+}
+
+func (l *LifeProc) OnStop(reason error) {
+	l.events <- fmt.Sprintf("stop:%v", reason)
+}
+
+func (l *LifeProc) OnRestart(err error) {
+	l.loaded = errors.Is(err, ErrPanic)
+	l.events <- "restart"
+}
+
+func (l *LifeProc) Loaded(arg *LifeArgs, reply *bool) error {
+	*reply = l.loaded
+	return nil
+}
+
+func (l *LifeProc) Boom(arg *LifeArgs, reply *bool) error {
+	panic("boom")
+}
+
+func TestLifecycle(t *testing.T) {
+	events := make(chan string, 10)
+	g, err := NewGPC(&LifeProc{events: events},
+		Supervise(func(*PanicError) Directive {
+			return Restart
+		}),
+		Factory(func() (interface{}, error) {
+			return &LifeProc{events: events}, nil
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = g.getMethod("LifeProc.OnStart"); !errors.Is(err, ErrMethodNotFound) {
+		t.Fatalf("OnStart registered as method: %v", err)
+	}
+	go g.Run()
+
+	var loaded bool
+	if err = g.Call("LifeProc.Loaded", &LifeArgs{}, &loaded); err != nil || !loaded {
+		t.Fatalf("Loaded = %v, %v", loaded, err)
+	}
+	g.Call("LifeProc.Boom", &LifeArgs{}, &loaded)
+	if e := <-events; e != "restart" {
+		t.Fatalf("event = %v", e)
+	}
+	// 重启后不调用OnStart，由OnRestart恢复状态
+	if err = g.Call("LifeProc.Loaded", &LifeArgs{}, &loaded); err != nil || !loaded {
+		t.Fatalf("Loaded after restart = %v, %v", loaded, err)
+	}
+	g.Stop(context.Background())
+	if e := <-events; e != "stop:<nil>" {
+		t.Fatalf("event = %v", e)
+	}
+
+	// OnStart失败时Run退出，不调用OnStop
+	startErr := errors.New("load failed")
+	g, _ = NewGPC(&LifeProc{events: events, startErr: startErr})
+	go g.Run()
+	<-g.Done()
+	if !errors.Is(g.Err(), startErr) {
+		t.Fatalf("Err = %v", g.Err())
+	}
+	if err = g.Call("LifeProc.Loaded", &LifeArgs{}, &loaded); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Call after start failed = %v", err)
+	}
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected event %v", e)
+	default:
+	}
+
+	// GPCFast通过处理器设置
+	handler := newCounterHandler()
+	handler.SetStartHandle(func(ctx context.Context) error {
+		events <- "fast start"
+		return nil
+	})
+	handler.SetStopHandle(func(reason error) {
+		events <- "fast stop"
+	})
+	fast := NewGPCFast(handler)
+	go fast.Run()
+	fast.Stop(context.Background())
+	if e1, e2 := <-events, <-events; e1 != "fast start" || e2 != "fast stop" {
+		t.Fatalf("events = %v, %v", e1, e2)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	fired := make(chan string, 100)
+	handler := NewHandler()
+	handler.RegisterHandle("fire", func(param interface{}, result interface{}) error {
+		fired <- param.(string)
+		return nil
+	})
+	g := NewGPCFast(handler)
+	go g.Run()
+	defer g.Close()
+
+	start := time.Now()
+	g.GoAfter(20*time.Millisecond, "fire", "after")
+	g.GoAt(start.Add(10*time.Millisecond), "fire", "at")
+	canceled := g.GoAfter(10*time.Millisecond, "fire", "canceled")
+	if !canceled.Stop() || canceled.Stop() {
+		t.Fatalf("Stop should succeed only once")
+	}
+	if s := <-fired; s != "at" {
+		t.Fatalf("first fired %v", s)
+	}
+	if s := <-fired; s != "after" || time.Since(start) < 20*time.Millisecond {
+		t.Fatalf("second fired %v after %v", s, time.Since(start))
+	}
+
+	every := g.Every(5*time.Millisecond, "fire", "every")
+	for i := 0; i < 3; i++ {
+		if s := <-fired; s != "every" {
+			t.Fatalf("fired %v", s)
+		}
+	}
+	if !every.Stop() {
+		t.Fatalf("Every Stop failed")
+	}
+	// 停止后已投递的消息也不再执行
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case s := <-fired:
+		t.Fatalf("fired %v after Stop", s)
+	default:
+	}
+}
+
+func TestFixedStep(t *testing.T) {
+	type frameInfo struct {
+		frame int64
+		dt    int32
+	}
+	clock := NewFakeClock(time.Unix(0, 0))
+	var infos []frameInfo
+	var overruns []int64
+	stall := false
+	handler := NewHandler()
+	handler.RegisterHandle("noop", func(param interface{}, result interface{}) error {
+		return nil
+	})
+	handler.SetFrameTickHandle(func(frame int64, dt int32) {
+		infos = append(infos, frameInfo{frame, dt})
+		if stall && frame == 5 {
+			// 停顿100毫秒，之后一次追赶3帧，其余放弃
+			clock.Advance(100 * time.Millisecond)
+		}
+	})
+	options := []GPCOption{TickMs(10), FixedStep(3), WithClock(clock), OnTickOverrun(func(frame int64, cost time.Duration) {
+		overruns = append(overruns, frame)
+	})}
+	g := NewGPCFast(handler, options...)
+	go g.Run()
+	var r int
+	// 保证Run已经开始
+	if err := g.Call("noop", nil, &r); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(40 * time.Millisecond)
+	g.Call("noop", nil, &r)
+	g.Stop(context.Background())
+	if len(infos) != 4 {
+		t.Fatalf("frames %v", infos)
+	}
+
+	// 停顿和追赶直接驱动帧计时，运行Run时不能在处理函数中推进FakeClock
+	stall = true
+	g = NewGPCFast(handler, options...)
+	fc := frameClock{start: time.Unix(0, 0), frame: 4}
+	clock.Advance(10 * time.Millisecond)
+	g.tickFrames(&fc, clock.Now())
+	// 停顿后的3帧在同一次推进中执行
+	catchUp := len(infos)
+	g.tickFrames(&fc, clock.Now())
+	if n := len(infos) - catchUp; n != 3 {
+		t.Fatalf("caught up %v frames", n)
+	}
+	// 第9帧在下一个步长
+	g.tickFrames(&fc, clock.Now())
+	if len(infos) != 8 {
+		t.Fatalf("frame came before its step: %v", infos)
+	}
+	clock.Advance(10 * time.Millisecond)
+	g.tickFrames(&fc, clock.Now())
+	for i, info := range infos {
+		if info.frame != int64(i+1) || info.dt != 10 {
+			t.Fatalf("frame %v = %v, dt %v", i+1, info.frame, info.dt)
+		}
+	}
+	if len(infos) != 9 {
+		t.Fatalf("frames %v", infos)
+	}
+	if len(overruns) != 1 || overruns[0] != 5 {
+		t.Fatalf("overruns %v", overruns)
+	}
+}
+
+func TestTickGroup(t *testing.T) {
+	var barrierFrame int64
+	var bad int32
+	var barrierCount int32
+	tg := NewTickGroup(5, Barrier(func(frame int64) {
+		atomic.StoreInt64(&barrierFrame, frame)
+		atomic.AddInt32(&barrierCount, 1)
+	}))
+	var members []*GPCFast
+	for i := 0; i < 5; i++ {
+		i := i
+		handler := NewHandler()
+		handler.SetFrameTickHandle(func(frame int64, dt int32) {
+			// 所有成员完成上一帧之后才开始本帧
+			if atomic.LoadInt64(&barrierFrame) != frame-1 || dt != 5 {
+				atomic.StoreInt32(&bad, 1)
+			}
+			time.Sleep(time.Duration(i) * time.Millisecond)
+		})
+		g := NewGPCFast(handler, TickMs(1000))
+		go g.Run()
+		tg.Add(g)
+		members = append(members, g)
+	}
+	tg.Start()
+	for atomic.LoadInt64(&barrierFrame) < 5 {
+		time.Sleep(time.Millisecond)
+	}
+	// 成员退出后不阻塞组
+	members[4].Stop(context.Background())
+	frame := atomic.LoadInt64(&barrierFrame)
+	for atomic.LoadInt64(&barrierFrame) < frame+5 {
+		time.Sleep(time.Millisecond)
+	}
+	tg.Stop()
+	for _, g := range members {
+		g.Close()
+	}
+	if atomic.LoadInt32(&bad) != 0 {
+		t.Fatalf("frame started before barrier")
+	}
+	if n := atomic.LoadInt32(&barrierCount); int64(n) != tg.Frame() {
+		t.Fatalf("barrier called %v times for %v frames", n, tg.Frame())
+	}
+}
+
+func TestStop(t *testing.T) {
+	for _, reject := range []bool{false, true} {
+		block := make(chan struct{})
+		handler := newCounterHandler()
+		handler.RegisterHandle("block", func(param interface{}, result interface{}) error {
+			<-block
+			return nil
+		})
+		options := []GPCOption{NoCallTimeout()}
+		if reject {
+			options = append(options, RejectOnStop())
+		}
+		g := NewGPCFast(handler, options...)
+		go g.Run()
+
+		g.Go("block", nil)
+		n := 10
+		futures := make([]*Future, n)
+		results := make([]int, n)
+		for i := range futures {
+			futures[i] = g.CallAsync("incr", nil, &results[i])
+		}
+		stopErr := make(chan error)
+		go func() {
+			stopErr <- g.Stop(context.Background())
+		}()
+		<-g.closeChan
+		close(block)
+		if err := <-stopErr; err != nil {
+			t.Fatal(err)
+		}
+		for i, f := range futures {
+			err := f.Wait()
+			if reject && !errors.Is(err, ErrClosed) {
+				t.Errorf("reject: future %v err = %v", i, err)
+			}
+			if !reject && (err != nil || results[i] != i+1) {
+				t.Errorf("drain: future %v err = %v, result %v", i, err, results[i])
+			}
+		}
+		if err := g.Call("incr", nil, &n); !errors.Is(err, ErrClosed) {
+			t.Errorf("call after stop err = %v", err)
+		}
+		if err := g.GoContext(context.Background(), "incr", nil); !errors.Is(err, ErrClosed) {
+			t.Errorf("go after stop err = %v", err)
+		}
+		select {
+		case <-g.Done():
+		default:
+			t.Error("Done not closed")
+		}
+		if err := g.Err(); err != nil {
+			t.Errorf("Err() = %v", err)
+		}
+	}
+
+	// 处理剩余消息时ctx结束，拒绝剩余的消息
+	block := make(chan struct{})
+	handler := NewHandler()
+	handler.RegisterHandle("block", func(param interface{}, result interface{}) error {
+		<-block
+		return nil
+	})
+	g := NewGPCFast(handler, NoCallTimeout())
+	go g.Run()
+	g.Go("block", nil)
+	f := g.CallAsync("block", nil, &struct{}{})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	go func() {
+		<-g.abortChan
+		close(block)
+	}()
+	if err := g.Stop(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Stop err = %v", err)
+	}
+	if err := f.Wait(); !errors.Is(err, ErrClosed) {
+		t.Errorf("aborted future err = %v", err)
+	}
+
+	// 没有运行Run时ctx结束后返回
+	g = NewGPCFast(NewHandler())
+	if err := stopWithin(g, 20*time.Millisecond); err != context.DeadlineExceeded {
+		t.Errorf("never run: Stop err = %v", err)
+	}
+
+	// 处理函数一直不返回时ctx结束后返回
+	hang := make(chan struct{})
+	defer close(hang)
+	handler = NewHandler()
+	handler.RegisterHandle("hang", func(param interface{}, result interface{}) error {
+		<-hang
+		return nil
+	})
+	g = NewGPCFast(handler)
+	go g.Run()
+	g.Go("hang", nil)
+	if err := stopWithin(g, 20*time.Millisecond); err != context.DeadlineExceeded {
+		t.Errorf("hung handler: Stop err = %v", err)
+	}
+}
+
+// 用timeout调用Stop，Stop没有及时返回时失败
+func stopWithin(g Actor, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	stopErr := make(chan error, 1)
+	go func() {
+		stopErr <- g.Stop(ctx)
+	}()
+	select {
+	case err := <-stopErr:
+		return err
+	case <-time.After(time.Second):
+		return errors.New("Stop did not return")
+	}
+}
+
+func TestCallError(t *testing.T) {
+	block := make(chan struct{})
+	handler := newCounterHandler()
+	handler.RegisterHandle("block", func(param interface{}, result interface{}) error {
+		<-block
+		return nil
+	})
+	fast := NewGPCFast(handler, CallTimeout(10))
+	go fast.Run()
+	slow, _ := NewGPC(&EchoProc{})
+	go slow.Run()
+
+	var n int
+	cases := []struct {
+		err    error
+		target error
+		method string
+	}{
+		{fast.Call("none", nil, &n), ErrMethodNotFound, "none"},
+		{fast.Call("boom", nil, &n), ErrPanic, "boom"},
+		{fast.Call("block", nil, &n), ErrTimeout, "block"},
+		{slow.Call("EchoProc", &EchoArgs{}, &EchoReply{}), ErrBadMethodName, "EchoProc"},
+		{slow.Call("None.Echo", &EchoArgs{}, &EchoReply{}), ErrServiceNotFound, "None.Echo"},
+		{slow.Call("EchoProc.None", &EchoArgs{}, &EchoReply{}), ErrMethodNotFound, "EchoProc.None"},
+	}
+	close(block)
+	fast.Stop(context.Background())
+	cases = append(cases, struct {
+		err    error
+		target error
+		method string
+	}{fast.Call("incr", nil, &n), ErrClosed, "incr"})
+	slow.Close()
+
+	for _, c := range cases {
+		if !errors.Is(c.err, c.target) {
+			t.Errorf("err = %v, want %v", c.err, c.target)
+		}
+		var cerr *CallError
+		if !errors.As(c.err, &cerr) || cerr.Method != c.method {
+			t.Errorf("err = %v, want CallError of method %v", c.err, c.method)
+		}
+	}
+
+	// ctx超时也是ErrTimeout
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	g := NewGPCFast(NewHandler())
+	if err := g.CallContext(ctx, "block", nil, &n); !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ctx timeout err = %v", err)
+	}
+}
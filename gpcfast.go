@@ -1,19 +1,31 @@
 package gpc
 
 import (
+	"context"
 	"fmt"
 )
 
 // 调用处理器
 type Handler struct {
-	handlerMap map[string]func(param interface{}, result interface{}) error
-	tickHandle func(tick int32)
+	handlerMap  map[string]func(param interface{}, result interface{}) error
+	typedMap    map[string]interface{} // Register注册的类型安全处理函数
+	tickHandle  func(tick int32)
+	frameHandle func(frame int64, dt int32)
+	onStart     func(ctx context.Context) error
+	onStop      func(reason error)
+	onRestart   func(err error)
+	onPassivate func()
+	onTerminate func(t Terminated)
+	owner       *GPCFast // 使用该处理器的gpc
+	shared      bool     // 被多个gpc使用，不能注册类型安全的处理函数
+	priorities  map[string]int
 }
 
 // 创建调用处理器
 func NewHandler() *Handler {
 	return &Handler{
 		handlerMap: make(map[string]func(param interface{}, result interface{}) error),
+		typedMap:   make(map[string]interface{}),
 	}
 }
 
@@ -22,16 +34,55 @@ func (h *Handler) RegisterHandle(method string, handleFunc func(param interface{
 	h.handlerMap[method] = handleFunc
 }
 
+// 注册一个有优先级的处理函数，调用时没有指定优先级则使用priority
+func (h *Handler) RegisterPriorityHandle(method string, priority int, handleFunc func(param interface{}, result interface{}) error) {
+	h.RegisterHandle(method, handleFunc)
+	if h.priorities == nil {
+		h.priorities = make(map[string]int)
+	}
+	h.priorities[method] = priority
+}
+
 // 设置定时器函数
 func (h *Handler) SetTickHandle(method string, handleFunc func(int32)) {
 	h.tickHandle = handleFunc
 }
 
+// 设置固定步长模式的定时器函数
+func (h *Handler) SetFrameTickHandle(handleFunc func(frame int64, dt int32)) {
+	h.frameHandle = handleFunc
+}
+
+// 设置启动函数，在Run所在协程中处理消息之前调用，返回错误时Run退出
+func (h *Handler) SetStartHandle(handleFunc func(ctx context.Context) error) {
+	h.onStart = handleFunc
+}
+
+// 设置停止函数，Run退出前在处理完剩余消息之后调用
+func (h *Handler) SetStopHandle(handleFunc func(reason error)) {
+	h.onStop = handleFunc
+}
+
+// 设置重启函数，监督策略重启后在工厂创建的新处理器上调用
+func (h *Handler) SetRestartHandle(handleFunc func(err error)) {
+	h.onRestart = handleFunc
+}
+
+// 设置钝化函数，虚拟gpc被钝化时在处理完剩余消息之后、停止函数之前调用
+func (h *Handler) SetPassivateHandle(handleFunc func()) {
+	h.onPassivate = handleFunc
+}
+
+// 设置监视的gpc退出时的处理函数
+func (h *Handler) SetTerminatedHandle(handleFunc func(t Terminated)) {
+	h.onTerminate = handleFunc
+}
+
 // 外部调用处理
 func (h *Handler) Handle(method string, param interface{}, result interface{}) error {
 	handle, o := h.handlerMap[method]
 	if !o {
-		return fmt.Errorf("gpc: Not found handle with method name %v", method)
+		return callError(method, ErrMethodNotFound)
 	}
 	return handle(param, result)
 }
@@ -47,19 +98,65 @@ func NewGPCFast(handler *Handler, options ...GPCOption) *GPCFast {
 	gpc := &GPCFast{
 		handler: handler,
 	}
+	// 类型安全的句柄通过owner调用，被多个gpc使用时无法确定调用哪一个
+	if handler.owner != nil {
+		if len(handler.typedMap) > 0 {
+			panic("gpc: handler with typed methods cant be shared by several GPCFast")
+		}
+		handler.shared = true
+	}
+	handler.owner = gpc
 	for _, option := range options {
-		option(gpc.options)
+		option(&gpc.options)
+	}
+	if gpc.options.name == "" {
+		gpc.options.name = "GPCFast"
+	}
+	for method, priority := range handler.priorities {
+		if _, ok := gpc.options.priorities[method]; !ok {
+			gpc.options.SetMethodPriority(method, priority)
+		}
 	}
-	gpc.init(gpc.callMethod, gpc.postMethod, handler.tickHandle)
+	gpc.init(gpc, gpc.callMethod, gpc.postMethod, handler.tickHandle)
+	gpc.frameMethodFunc = handler.frameHandle
+	gpc.restartFunc = gpc.restart
+	gpc.setHandlerHooks(handler)
 	return gpc
 }
 
+// 用工厂重新创建处理器，在Run所在协程中调用
+func (g *GPCFast) restart() error {
+	serv, err := g.options.factory()
+	if err != nil {
+		return err
+	}
+	handler, ok := serv.(*Handler)
+	if !ok {
+		return fmt.Errorf("gpc: factory of GPCFast must return *Handler, got %T", serv)
+	}
+	handler.owner = g
+	g.handler = handler
+	g.tickMethodFunc = handler.tickHandle
+	g.frameMethodFunc = handler.frameHandle
+	g.setHandlerHooks(handler)
+	return nil
+}
+
+// 从处理器中获取生命周期函数
+func (g *GPCFast) setHandlerHooks(handler *Handler) {
+	g.onStartFunc = handler.onStart
+	g.onStopFunc = handler.onStop
+	g.onRestartFunc = handler.onRestart
+	g.onPassivateFunc = handler.onPassivate
+	g.onTerminateFunc = handler.onTerminate
+}
+
 // Run中调用的处理函数，因为go无法支持在一个类型中的方法中调用接口达到虚函数的效果
-func (g *GPCFast) callMethod(method string, param interface{}, result interface{}) error {
-	return g.handler.Handle(method, param, result)
+func (g *GPCFast) callMethod(d *data) error {
+	return g.handler.Handle(d.method, d.param, d.future.result)
 }
 
 // 不需要返回值的函数
-func (g *GPCFast) postMethod(method string, param interface{}) {
-	g.handler.Handle(method, param, nil)
+func (g *GPCFast) postMethod(d *data) {
+	g.handler.Handle(d.method, d.param, nil)
 }
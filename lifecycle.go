@@ -0,0 +1,116 @@
+package gpc
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+)
+
+// 可选的启动接口，在Run所在协程中处理消息之前调用
+// 返回错误时Run退出，Err返回该错误，已入队的消息被拒绝；关闭gpc时ctx被取消
+type Starter interface {
+	OnStart(ctx context.Context) error
+}
+
+// 可选的停止接口，Run退出前在处理完剩余消息之后调用，reason为停止的原因，正常关闭为nil
+// OnStart返回错误时不调用
+type Stopper interface {
+	OnStop(reason error)
+}
+
+// 可选的重启接口，监督策略重启服务后在新服务上调用，err为导致重启的*PanicError
+type Restarter interface {
+	OnRestart(err error)
+}
+
+// 是否定时器或生命周期方法，不作为服务方法注册
+func isLifecycleMethod(name string) bool {
+	switch name {
+	case "Tick", "FrameTick", "OnStart", "OnStop", "OnRestart", "OnPassivate", "OnTerminated":
+		return true
+	}
+	return false
+}
+
+// 从服务中获取生命周期函数
+func (g *gpcBase) setServiceHooks(serv interface{}) {
+	g.onStartFunc, g.onStopFunc, g.onRestartFunc = nil, nil, nil
+	g.onPassivateFunc, g.onTerminateFunc = nil, nil
+	if s, ok := serv.(Starter); ok {
+		g.onStartFunc = s.OnStart
+	}
+	if s, ok := serv.(Stopper); ok {
+		g.onStopFunc = s.OnStop
+	}
+	if s, ok := serv.(Restarter); ok {
+		g.onRestartFunc = s.OnRestart
+	}
+	if s, ok := serv.(Passivator); ok {
+		g.onPassivateFunc = s.OnPassivate
+	}
+	if s, ok := serv.(Watcher); ok {
+		g.onTerminateFunc = s.OnTerminated
+	}
+}
+
+// 调用OnStart，panic时返回*PanicError
+func (g *gpcBase) callOnStart() (err error) {
+	if g.onStartFunc == nil {
+		return nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = g.recovered("OnStart", r)
+		}
+	}()
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), actorKey{}, g.self))
+	defer cancel()
+	go func() {
+		select {
+		case <-g.closeChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return g.onStartFunc(ctx)
+}
+
+// 调用OnStop，panic时只记录日志
+func (g *gpcBase) callOnStop(reason error) {
+	if g.onStopFunc == nil {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("gpc: %v %v", g.Name(), g.recovered("OnStop", r))
+		}
+	}()
+	g.onStopFunc(reason)
+}
+
+// 重启后调用OnRestart，panic时返回*PanicError
+func (g *gpcBase) callOnRestart(cause error) (err error) {
+	if g.onRestartFunc == nil {
+		return nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = g.recovered("OnRestart", r)
+		}
+	}()
+	g.onRestartFunc(cause)
+	return nil
+}
+
+// 被钝化时调用OnPassivate，panic时只记录日志
+func (g *gpcBase) callOnPassivate() {
+	if g.onPassivateFunc == nil || atomic.LoadInt32(&g.passivating) == 0 || g.stopReason != nil {
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("gpc: %v %v", g.Name(), g.recovered("OnPassivate", r))
+		}
+	}()
+	g.onPassivateFunc()
+}
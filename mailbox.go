@@ -0,0 +1,320 @@
+package gpc
+
+import (
+	"container/heap"
+	"sync"
+	"sync/atomic"
+)
+
+// 邮箱的类型
+type MailboxType int
+
+const (
+	MailboxChannel   MailboxType = iota // 有界通道，默认
+	MailboxUnbounded                    // 无界链表队列，不会满，发送方从不阻塞
+	MailboxPriority                     // 有界优先级队列，优先级高的消息先处理，相同时先进先出，优先级用WithPriority或MethodPriority设置
+	MailboxRing                         // 无锁的有界环形队列，多个发送方一个接收方，容量向上取整为2的幂
+)
+
+// 邮箱，保存gpc待处理的消息，可以有多个发送方，Run所在协程接收
+// 放入和取出都不阻塞，等待由gpc处理：放入后通知Run，取出后唤醒等待空位的发送方
+// 通过WithMailbox选择实现，容量由ChannelLen决定
+type Mailbox interface {
+	// 放入消息，满时返回false
+	Push(d *data) bool
+	// 取出一条消息，没有时返回nil，OverflowDropOldest时发送方也会调用
+	Pop() *data
+	// 消息数
+	Len() int
+}
+
+// 创建邮箱
+func newMailbox(mailboxType MailboxType, capacity int) Mailbox {
+	switch mailboxType {
+	case MailboxUnbounded:
+		return &linkedMailbox{}
+	case MailboxPriority:
+		return &priorityMailbox{capacity: capacity}
+	case MailboxRing:
+		return newRingMailbox(capacity)
+	default:
+		return chanMailbox(make(chan *data, capacity))
+	}
+}
+
+// 有界通道
+type chanMailbox chan *data
+
+func (m chanMailbox) Push(d *data) bool {
+	select {
+	case m <- d:
+		return true
+	default:
+		return false
+	}
+}
+
+func (m chanMailbox) Pop() *data {
+	select {
+	case d := <-m:
+		return d
+	default:
+		return nil
+	}
+}
+
+func (m chanMailbox) Len() int {
+	return len(m)
+}
+
+// 链表队列，消息放在邮箱分配的节点中串起来，capacity<=0时无界
+type linkedMailbox struct {
+	locker   sync.Mutex
+	capacity int
+	head     *linkedNode
+	tail     *linkedNode
+	length   int32 // 原子操作
+}
+
+// 链表队列的节点，默认的通道邮箱不需要next，不放在消息中
+type linkedNode struct {
+	d    *data
+	next *linkedNode
+}
+
+func (m *linkedMailbox) Push(d *data) bool {
+	m.locker.Lock()
+	if m.capacity > 0 && int(m.length) >= m.capacity {
+		m.locker.Unlock()
+		return false
+	}
+	node := &linkedNode{d: d}
+	if m.tail == nil {
+		m.head = node
+	} else {
+		m.tail.next = node
+	}
+	m.tail = node
+	atomic.AddInt32(&m.length, 1)
+	m.locker.Unlock()
+	return true
+}
+
+func (m *linkedMailbox) Pop() *data {
+	if atomic.LoadInt32(&m.length) == 0 {
+		return nil
+	}
+	m.locker.Lock()
+	node := m.head
+	if node == nil {
+		m.locker.Unlock()
+		return nil
+	}
+	m.head = node.next
+	if m.head == nil {
+		m.tail = nil
+	}
+	atomic.AddInt32(&m.length, -1)
+	m.locker.Unlock()
+	return node.d
+}
+
+func (m *linkedMailbox) Len() int {
+	return int(atomic.LoadInt32(&m.length))
+}
+
+// 有界优先级队列
+type priorityMailbox struct {
+	locker   sync.Mutex
+	capacity int
+	queue    priorityQueue
+	seq      uint64
+	length   int32 // 原子操作
+}
+
+// 优先级队列中的消息和入队顺序
+type priorityItem struct {
+	d   *data
+	seq uint64
+}
+
+// 按优先级从高到低、优先级相同时按入队顺序排列的堆
+// 放入和取出用append和removeAt，不经过heap.Push和heap.Pop，避免装箱分配
+type priorityQueue []priorityItem
+
+func (q priorityQueue) Len() int { return len(q) }
+func (q priorityQueue) Less(i, j int) bool {
+	if q[i].d.priority == q[j].d.priority {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].d.priority > q[j].d.priority
+}
+func (q priorityQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *priorityQueue) Push(x interface{}) {
+	*q = append(*q, x.(priorityItem))
+}
+func (q *priorityQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	old[len(old)-1] = priorityItem{}
+	*q = old[:len(old)-1]
+	return item
+}
+
+func (m *priorityMailbox) Push(d *data) bool {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	if len(m.queue) >= m.capacity {
+		return false
+	}
+	m.seq++
+	m.queue = append(m.queue, priorityItem{d: d, seq: m.seq})
+	heap.Fix(&m.queue, len(m.queue)-1)
+	atomic.AddInt32(&m.length, 1)
+	return true
+}
+
+// 移除堆中第i条消息，同heap.Remove，调用时要持有锁
+func (m *priorityMailbox) removeAt(i int) *data {
+	n := len(m.queue) - 1
+	d := m.queue[i].d
+	m.queue.Swap(i, n)
+	m.queue[n] = priorityItem{}
+	m.queue = m.queue[:n]
+	if i < n {
+		heap.Fix(&m.queue, i)
+	}
+	atomic.AddInt32(&m.length, -1)
+	return d
+}
+
+func (m *priorityMailbox) Pop() *data {
+	if atomic.LoadInt32(&m.length) == 0 {
+		return nil
+	}
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	if len(m.queue) == 0 {
+		return nil
+	}
+	return m.removeAt(0)
+}
+
+func (m *priorityMailbox) Len() int {
+	return int(atomic.LoadInt32(&m.length))
+}
+
+func (m *priorityMailbox) removeOldest() *data {
+	return m.remove(func(item, found *priorityItem) bool {
+		return found == nil || item.seq < found.seq
+	})
+}
+
+// 优先级相同时移除最晚放入的
+func (m *priorityMailbox) removeLower(priority int) *data {
+	return m.remove(func(item, found *priorityItem) bool {
+		if item.d.priority >= priority {
+			return false
+		}
+		return found == nil || item.d.priority < found.d.priority || (item.d.priority == found.d.priority && item.seq > found.seq)
+	})
+}
+
+// 移除better选出的非内部消息，better在found为nil时也要判断
+func (m *priorityMailbox) remove(better func(item, found *priorityItem) bool) *data {
+	m.locker.Lock()
+	defer m.locker.Unlock()
+	index := -1
+	var found *priorityItem
+	for i := range m.queue {
+		item := &m.queue[i]
+		if item.d.fn != nil {
+			continue
+		}
+		if better(item, found) {
+			index, found = i, item
+		}
+	}
+	if found == nil {
+		return nil
+	}
+	return m.removeAt(index)
+}
+
+// 无锁的有界环形队列，每个格子的序号表示格子的状态
+// 序号等于放入位置时可以放入，等于放入位置+1时可以取出
+type ringMailbox struct {
+	mask  uint64
+	cells []ringCell
+	_     [56]byte // 放入和取出的位置放在不同的缓存行
+	tail  uint64   // 下一个放入的位置，原子操作
+	_     [56]byte
+	head  uint64 // 下一个取出的位置，原子操作
+}
+
+type ringCell struct {
+	seq uint64
+	d   *data
+}
+
+func newRingMailbox(capacity int) *ringMailbox {
+	size := uint64(1)
+	for size < uint64(capacity) {
+		size <<= 1
+	}
+	m := &ringMailbox{
+		mask:  size - 1,
+		cells: make([]ringCell, size),
+	}
+	for i := range m.cells {
+		m.cells[i].seq = uint64(i)
+	}
+	return m
+}
+
+func (m *ringMailbox) Push(d *data) bool {
+	for {
+		pos := atomic.LoadUint64(&m.tail)
+		cell := &m.cells[pos&m.mask]
+		seq := atomic.LoadUint64(&cell.seq)
+		if diff := int64(seq - pos); diff == 0 {
+			if atomic.CompareAndSwapUint64(&m.tail, pos, pos+1) {
+				cell.d = d
+				atomic.StoreUint64(&cell.seq, pos+1)
+				return true
+			}
+		} else if diff < 0 {
+			// 格子中的消息还没有取出，队列满
+			return false
+		}
+	}
+}
+
+func (m *ringMailbox) Pop() *data {
+	for {
+		pos := atomic.LoadUint64(&m.head)
+		cell := &m.cells[pos&m.mask]
+		seq := atomic.LoadUint64(&cell.seq)
+		if diff := int64(seq - (pos + 1)); diff == 0 {
+			// 发送方丢弃最早的消息时也会取出，用CAS和接收方竞争
+			if atomic.CompareAndSwapUint64(&m.head, pos, pos+1) {
+				d := cell.d
+				cell.d = nil
+				atomic.StoreUint64(&cell.seq, pos+m.mask+1)
+				return d
+			}
+		} else if diff < 0 {
+			// 空或者发送方还没写完
+			return nil
+		}
+	}
+}
+
+func (m *ringMailbox) Len() int {
+	head := atomic.LoadUint64(&m.head)
+	tail := atomic.LoadUint64(&m.tail)
+	if tail <= head {
+		return 0
+	}
+	return int(tail - head)
+}
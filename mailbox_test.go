@@ -0,0 +1,258 @@
+package gpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+var mailboxTypes = []struct {
+	name        string
+	mailboxType MailboxType
+}{
+	{"Channel", MailboxChannel},
+	{"Unbounded", MailboxUnbounded},
+	{"Priority", MailboxPriority},
+	{"Ring", MailboxRing},
+}
+
+func TestMailbox(t *testing.T) {
+	for _, mt := range mailboxTypes {
+		m := newMailbox(mt.mailboxType, 4)
+		for i := 0; i < 4; i++ {
+			if !m.Push(&data{param: i}) {
+				t.Fatalf("%v: push %v failed", mt.name, i)
+			}
+		}
+		if full := !m.Push(&data{param: 4}); full != (mt.mailboxType != MailboxUnbounded) {
+			t.Fatalf("%v: full = %v", mt.name, full)
+		}
+		for i := 0; i < 4; i++ {
+			if d := m.Pop(); d == nil || d.param != i {
+				t.Fatalf("%v: pop %v, want %v", mt.name, d, i)
+			}
+		}
+		if mt.mailboxType == MailboxUnbounded {
+			m.Pop()
+		}
+		if m.Pop() != nil || m.Len() != 0 {
+			t.Fatalf("%v: not empty", mt.name)
+		}
+	}
+
+	m := newMailbox(MailboxPriority, 10)
+	for i, priority := range []int{0, 2, 1, 2, 0} {
+		m.Push(&data{param: i, priority: priority})
+	}
+	for _, want := range []int{1, 3, 2, 0, 4} {
+		if d := m.Pop(); d.param != want {
+			t.Fatalf("priority pop %v, want %v", d.param, want)
+		}
+	}
+}
+
+// 多个发送方并发放入，每个发送方的消息保持顺序
+func TestMailboxConcurrent(t *testing.T) {
+	const senders, count = 4, 10000
+	for _, mt := range mailboxTypes {
+		handler := NewHandler()
+		last := make([]int, senders)
+		handler.RegisterHandle("seq", func(param interface{}, result interface{}) error {
+			p := param.([2]int)
+			if p[1] != last[p[0]]+1 {
+				t.Errorf("%v: sender %v got %v after %v", mt.name, p[0], p[1], last[p[0]])
+			}
+			last[p[0]] = p[1]
+			return nil
+		})
+		handler.RegisterHandle("get", func(param interface{}, result interface{}) error {
+			return nil
+		})
+		g := NewGPCFast(handler, WithMailbox(mt.mailboxType), ChannelLen(16))
+		go g.Run()
+		var wg sync.WaitGroup
+		for s := 0; s < senders; s++ {
+			wg.Add(1)
+			go func(s int) {
+				defer wg.Done()
+				for i := 1; i <= count; i++ {
+					g.Go("seq", [2]int{s, i})
+				}
+			}(s)
+		}
+		wg.Wait()
+		var r int
+		g.Call("get", nil, &r)
+		for s, n := range last {
+			if n != count {
+				t.Fatalf("%v: sender %v got %v", mt.name, s, n)
+			}
+		}
+		g.Close()
+	}
+}
+
+func TestMailboxPriority(t *testing.T) {
+	a := newBlockedActor(WithMailbox(MailboxPriority), ChannelLen(4))
+	ctx := context.Background()
+	a.GoContext(WithPriority(ctx, PriorityLow), "record", 1)
+	a.GoContext(ctx, "record", 2)
+	a.GoContext(WithPriority(ctx, PriorityHigh), "record", 3)
+	a.GoContext(WithPriority(ctx, PriorityHigh), "record", 4)
+	if got := a.finish(); !equalInts(got, []int{3, 4, 2, 1}) {
+		t.Fatalf("recorded %v", got)
+	}
+}
+
+// 基准中的调用方，gpc和不经过邮箱的基线都实现了
+type benchCaller interface {
+	Go(methodName string, param interface{})
+	Call(methodName string, param interface{}, result interface{}) error
+}
+
+// 不经过邮箱的基线，一个协程从chan *data中取出消息直接执行g的处理函数，g不运行
+type chanBaseline struct {
+	g  *gpcBase
+	ch chan *data
+}
+
+func newChanBaseline(g *gpcBase, capacity int) *chanBaseline {
+	c := &chanBaseline{g: g, ch: make(chan *data, capacity)}
+	go func() {
+		for d := range c.ch {
+			err := g.handle(d)
+			if d.future != nil {
+				d.future.complete(err)
+			}
+		}
+	}()
+	return c
+}
+
+func (c *chanBaseline) Go(methodName string, param interface{}) {
+	c.ch <- &data{ctx: context.Background(), method: methodName, param: param}
+}
+
+func (c *chanBaseline) Call(methodName string, param interface{}, result interface{}) error {
+	future := newFuture(nil, methodName)
+	future.result = result
+	c.ch <- &data{ctx: context.Background(), method: methodName, param: param, future: future}
+	return future.Wait()
+}
+
+func (c *chanBaseline) Close() {
+	close(c.ch)
+}
+
+// TestFriend的负载，一个协程投递add和output，一个协程同步调用remove
+// chan为直接使用chan *data的基线
+func BenchmarkMailboxFriend(b *testing.B) {
+	newHandler := func() *Handler {
+		handler := NewHandler()
+		fmw := newFriendManagerWrapper()
+		handler.RegisterHandle("add", fmw.add)
+		handler.RegisterHandle("remove", fmw.remove)
+		handler.RegisterHandle("output", fmw.output)
+		handler.SetTickHandle("tick", fmw.tick)
+		return handler
+	}
+	b.Run("chan", func(b *testing.B) {
+		friendGpc := NewGPCFast(newHandler())
+		defer friendGpc.Close()
+		baseline := newChanBaseline(&friendGpc.gpcBase, 1024)
+		defer baseline.Close()
+		benchmarkFriend(b, baseline)
+	})
+	for _, mt := range mailboxTypes {
+		b.Run(mt.name, func(b *testing.B) {
+			friendGpc := NewGPCFast(newHandler(), WithMailbox(mt.mailboxType), ChannelLen(1024))
+			defer friendGpc.Close()
+			go friendGpc.Run()
+			benchmarkFriend(b, friendGpc)
+		})
+	}
+}
+
+func benchmarkFriend(b *testing.B, friendGpc benchCaller) {
+	b.ResetTimer()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for id := 1; id <= b.N; id++ {
+			friendGpc.Go("add", &friend{id: id, name: fmt.Sprintf("f_%v", id)})
+			friendGpc.Go("output", nil)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		var result bool
+		for id := b.N; id >= 1; id-- {
+			friendGpc.Call("remove", id, &result)
+			if result {
+				friendGpc.Go("output", nil)
+			}
+		}
+	}()
+	wg.Wait()
+}
+
+// TestFriend2的负载，一个协程投递NoAction，两个协程同步调用Add、Remove和Output
+// chan为直接使用chan *data的基线
+func BenchmarkMailboxFriend2(b *testing.B) {
+	b.Run("chan", func(b *testing.B) {
+		gpcFriend, err := NewGPC(newFriendManagerProc(), NoCallTimeout())
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer gpcFriend.Close()
+		baseline := newChanBaseline(&gpcFriend.gpcBase, 1024)
+		defer baseline.Close()
+		benchmarkFriend2(b, baseline)
+	})
+	for _, mt := range mailboxTypes {
+		b.Run(mt.name, func(b *testing.B) {
+			gpcFriend, err := NewGPC(newFriendManagerProc(), WithMailbox(mt.mailboxType), ChannelLen(1024), NoCallTimeout())
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer gpcFriend.Close()
+			go gpcFriend.Run()
+			benchmarkFriend2(b, gpcFriend)
+		})
+	}
+}
+
+func benchmarkFriend2(b *testing.B, gpcFriend benchCaller) {
+	b.ResetTimer()
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		noArgs := &NoActionArgs{}
+		for id := 1; id <= b.N; id++ {
+			gpcFriend.Go("FriendManagerProc.NoAction", noArgs)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		outputArgs, outputResult := &OutputArgs{}, &OutputResult{}
+		for id := 1; id <= b.N; id++ {
+			addArgs := &AddArgs{f: &friend{id: id, name: fmt.Sprintf("f_%v", id)}}
+			gpcFriend.Call("FriendManagerProc.Add", addArgs, &AddResult{})
+			gpcFriend.Call("FriendManagerProc.Output", outputArgs, outputResult)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		removeResult, outputArgs, outputResult := &RemoveResult{}, &OutputArgs{}, &OutputResult{}
+		for id := b.N; id >= 1; id-- {
+			gpcFriend.Call("FriendManagerProc.Remove", &RemoveArgs{id: id}, removeResult)
+			if removeResult.res {
+				gpcFriend.Call("FriendManagerProc.Output", outputArgs, outputResult)
+			}
+		}
+	}()
+	wg.Wait()
+}
@@ -1,9 +1,25 @@
 package gpc
 
 type Options struct {
-	chLen       int
-	callTimeout int   // 毫秒
-	tickMs      int32 // 定时器函数的调用间隔
+	chLen           int
+	callTimeout     int   // 毫秒
+	tickMs          int32 // 定时器函数的调用间隔
+	name            string
+	panicOnDeadlock bool // 检测到死锁时panic，用于调试
+	supervisor      SupervisorStrategy
+	supervisorHook  SupervisorHook
+	factory         func() (interface{}, error) // 重新创建服务的工厂
+	rejectOnStop    bool                        // 关闭时拒绝还在通道中的消息，默认处理完再退出
+	timerResolution int32                       // 时间轮的精度，毫秒
+	fixedStep       bool                        // 固定步长的定时器函数
+	maxCatchUp      int                         // 固定步长模式下停顿后一次最多追赶的帧数
+	tickOverrunHook TickOverrunHook
+	clock           Clock // 默认为精度timerResolution的RealClock
+	executor        *Executor
+	mailboxType     MailboxType
+	overflow        OverflowPolicy // 邮箱满时的策略
+	overflowTimeout int            // OverflowBlockTimeout最多阻塞的时间，毫秒
+	priorities      map[string]int // 方法的优先级
 }
 
 func (option *Options) SetChannelLen(length int) {
@@ -18,28 +34,202 @@ func (option *Options) SetTickMs(tickMs int32) {
 	option.tickMs = tickMs
 }
 
-type GPCOption func(Options)
+func (option *Options) SetName(name string) {
+	option.name = name
+}
+
+func (option *Options) SetPanicOnDeadlock(enable bool) {
+	option.panicOnDeadlock = enable
+}
+
+func (option *Options) SetSupervisor(strategy SupervisorStrategy) {
+	option.supervisor = strategy
+}
+
+func (option *Options) SetSupervisorHook(hook SupervisorHook) {
+	option.supervisorHook = hook
+}
+
+func (option *Options) SetFactory(factory func() (interface{}, error)) {
+	option.factory = factory
+}
+
+func (option *Options) SetRejectOnStop(reject bool) {
+	option.rejectOnStop = reject
+}
+
+func (option *Options) SetTimerResolutionMs(resolutionMs int32) {
+	option.timerResolution = resolutionMs
+}
+
+func (option *Options) SetFixedStep(maxCatchUp int) {
+	option.fixedStep = true
+	option.maxCatchUp = maxCatchUp
+}
+
+func (option *Options) SetTickOverrunHook(hook TickOverrunHook) {
+	option.tickOverrunHook = hook
+}
+
+func (option *Options) SetClock(clock Clock) {
+	option.clock = clock
+}
+
+func (option *Options) SetExecutor(executor *Executor) {
+	option.executor = executor
+}
+
+func (option *Options) SetMailbox(mailboxType MailboxType) {
+	option.mailboxType = mailboxType
+}
+
+func (option *Options) SetOverflow(policy OverflowPolicy, timeoutMs int) {
+	option.overflow = policy
+	option.overflowTimeout = timeoutMs
+}
+
+func (option *Options) SetMethodPriority(method string, priority int) {
+	if option.priorities == nil {
+		option.priorities = make(map[string]int)
+	}
+	option.priorities[method] = priority
+}
+
+type GPCOption func(*Options)
 
 func ChannelLen(length int) GPCOption {
-	return func(option Options) {
+	return func(option *Options) {
 		option.SetChannelLen(length)
 	}
 }
 
 func CallTimeout(timeout int) GPCOption {
-	return func(option Options) {
+	return func(option *Options) {
 		option.SetCallTimeout(timeout)
 	}
 }
 
 func NoCallTimeout() GPCOption {
-	return func(option Options) {
+	return func(option *Options) {
 		option.SetCallTimeout(GPC_CALL_NO_TIMEOUT)
 	}
 }
 
 func TickMs(tickMs int32) GPCOption {
-	return func(option Options) {
+	return func(option *Options) {
 		option.SetTickMs(tickMs)
 	}
-}
\ No newline at end of file
+}
+
+// gpc的名字，用于死锁等错误信息，默认为服务名
+func Name(name string) GPCOption {
+	return func(option *Options) {
+		option.SetName(name)
+	}
+}
+
+// 在处理函数中检测到死锁时直接panic，用于调试
+func PanicOnDeadlock() GPCOption {
+	return func(option *Options) {
+		option.SetPanicOnDeadlock(true)
+	}
+}
+
+// 处理函数panic后的监督策略，默认为Resume
+func Supervise(strategy SupervisorStrategy) GPCOption {
+	return func(option *Options) {
+		option.SetSupervisor(strategy)
+	}
+}
+
+// 每次执行监督指令后调用的钩子
+func OnSupervise(hook SupervisorHook) GPCOption {
+	return func(option *Options) {
+		option.SetSupervisorHook(hook)
+	}
+}
+
+// Restart时重新创建服务的工厂，NewGPC需要返回服务，NewGPCFast需要返回*Handler
+func Factory(factory func() (interface{}, error)) GPCOption {
+	return func(option *Options) {
+		option.SetFactory(factory)
+	}
+}
+
+// 关闭时处理完通道中的消息再退出，默认的方式
+func DrainOnStop() GPCOption {
+	return func(option *Options) {
+		option.SetRejectOnStop(false)
+	}
+}
+
+// 关闭时拒绝通道中的消息，同步调用返回ErrClosed
+func RejectOnStop() GPCOption {
+	return func(option *Options) {
+		option.SetRejectOnStop(true)
+	}
+}
+
+// 调用超时、定时器函数和定时消息使用的时间轮精度，精度相同的gpc共用一个时间轮
+func TimerResolutionMs(resolutionMs int32) GPCOption {
+	return func(option *Options) {
+		option.SetTimerResolutionMs(resolutionMs)
+	}
+}
+
+// 固定步长模式，定时器函数每帧收到固定的tickMs和递增的帧号，和调度的抖动无关
+// 停顿后补上落后的帧，一次最多追赶maxCatchUp帧，超过的部分放弃，maxCatchUp<=0时使用默认值
+func FixedStep(maxCatchUp int) GPCOption {
+	return func(option *Options) {
+		option.SetFixedStep(maxCatchUp)
+	}
+}
+
+// 固定步长模式下一帧的处理时间超过tickMs时调用的钩子
+func OnTickOverrun(hook TickOverrunHook) GPCOption {
+	return func(option *Options) {
+		option.SetTickOverrunHook(hook)
+	}
+}
+
+// 调用超时、定时器函数和定时消息使用的时钟，测试时可以用FakeClock
+func WithClock(clock Clock) GPCOption {
+	return func(option *Options) {
+		option.SetClock(clock)
+	}
+}
+
+// 在Executor的工作协程中运行，不使用自己的Run协程，默认使用独占的协程
+func RunOn(executor *Executor) GPCOption {
+	return func(option *Options) {
+		option.SetExecutor(executor)
+	}
+}
+
+// 邮箱的类型，默认为MailboxChannel
+func WithMailbox(mailboxType MailboxType) GPCOption {
+	return func(option *Options) {
+		option.SetMailbox(mailboxType)
+	}
+}
+
+// 邮箱满时的策略，默认为OverflowBlock，OverflowShedLowPriority要和WithMailbox(MailboxPriority)一起使用
+func Overflow(policy OverflowPolicy) GPCOption {
+	return func(option *Options) {
+		option.SetOverflow(policy, 0)
+	}
+}
+
+// 邮箱满时最多阻塞timeoutMs毫秒，超时返回ErrMailboxFull
+func BlockTimeout(timeoutMs int) GPCOption {
+	return func(option *Options) {
+		option.SetOverflow(OverflowBlockTimeout, timeoutMs)
+	}
+}
+
+// 设置方法的优先级，GPC的方法名为"服务名.方法名"
+func MethodPriority(method string, priority int) GPCOption {
+	return func(option *Options) {
+		option.SetMethodPriority(method, priority)
+	}
+}
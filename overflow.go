@@ -0,0 +1,147 @@
+package gpc
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// 邮箱满时的策略
+// 丢弃的同步调用返回ErrMailboxFull，丢弃的无返回值调用不返回错误，都计入Dropped
+// 回调、定时消息等内部消息不受策略影响，总是等待空位，也不会被丢弃
+type OverflowPolicy int
+
+const (
+	OverflowBlock           OverflowPolicy = iota // 阻塞直到有空位，默认
+	OverflowBlockTimeout                          // 最多阻塞BlockTimeout设置的时间，超时返回ErrMailboxFull
+	OverflowFail                                  // 立即返回ErrMailboxFull
+	OverflowDropNewest                            // 丢弃要放入的消息
+	OverflowDropOldest                            // 丢弃邮箱中最早的消息，再放入新消息
+	OverflowShedLowPriority                       // 丢弃邮箱中优先级最低且低于新消息的消息，没有时丢弃新消息，只能和MailboxPriority一起使用
+)
+
+// 可以由发送方移除消息的邮箱，没有实现时用Pop移除最早的消息
+type evictableMailbox interface {
+	// 移除最早的非内部消息
+	removeOldest() *data
+	// 移除优先级最低且低于priority的非内部消息
+	removeLower(priority int) *data
+}
+
+// 邮箱满时按策略处理，调用时要持有sendLocker的读锁
+func (g *gpcBase) overflow(ctx context.Context, d *data) error {
+	policy := g.options.overflow
+	if d.fn != nil {
+		policy = OverflowBlock
+	} else if d.try && (policy == OverflowBlock || policy == OverflowBlockTimeout) {
+		policy = OverflowFail
+	}
+	lane := g.laneOf(d)
+	switch policy {
+	case OverflowBlockTimeout:
+		timeout := make(chan struct{})
+		timer := g.clock.AfterFunc(time.Duration(g.options.overflowTimeout)*time.Millisecond, func() {
+			close(timeout)
+		})
+		defer timer.Stop()
+		return g.wait(ctx, d, timeout)
+	case OverflowFail:
+		return ErrMailboxFull
+	case OverflowDropNewest:
+		return g.dropNewest(d)
+	case OverflowDropOldest:
+		for {
+			old := g.evictOldest(lane)
+			if old == nil {
+				return g.dropNewest(d)
+			}
+			g.drop(old)
+			if g.push(d) {
+				return nil
+			}
+		}
+	case OverflowShedLowPriority:
+		// 创建时已检查邮箱为MailboxPriority
+		m := lane.(evictableMailbox)
+		for {
+			old := m.removeLower(d.priority)
+			if old == nil {
+				return g.dropNewest(d)
+			}
+			g.drop(old)
+			if g.push(d) {
+				return nil
+			}
+		}
+	}
+	return g.wait(ctx, d, nil)
+}
+
+// 丢弃要放入的消息，同步调用返回ErrMailboxFull
+func (g *gpcBase) dropNewest(d *data) error {
+	atomic.AddUint64(&g.dropped, 1)
+	if d.future != nil {
+		return ErrMailboxFull
+	}
+	return nil
+}
+
+// 丢弃已在邮箱中的消息，同步调用以ErrMailboxFull完成
+func (g *gpcBase) drop(d *data) {
+	atomic.AddUint64(&g.dropped, 1)
+	if d.future != nil {
+		d.future.complete(callError(d.method, ErrMailboxFull))
+	}
+}
+
+// 移除lane中最早的非内部消息，取出的内部消息放回队尾
+func (g *gpcBase) evictOldest(lane Mailbox) *data {
+	if m, ok := lane.(evictableMailbox); ok {
+		return m.removeOldest()
+	}
+	for n := lane.Len(); n > 0; n-- {
+		d := lane.Pop()
+		if d == nil {
+			return nil
+		}
+		if d.fn == nil {
+			return d
+		}
+		if !g.push(d) {
+			go g.send(d.ctx, d)
+		}
+	}
+	return nil
+}
+
+// 邮箱满时丢弃的消息数
+func (g *gpcBase) Dropped() uint64 {
+	return atomic.LoadUint64(&g.dropped)
+}
+
+// 无返回值调用，邮箱满时不阻塞，阻塞的策略按OverflowFail处理
+func (g *gpcBase) TryGo(methodName string, param interface{}) error {
+	ctx := context.Background()
+	d := &data{
+		ctx:    ctx,
+		method: methodName,
+		param:  param,
+		try:    true,
+	}
+	return g.goData(ctx, d)
+}
+
+// 同步调用，邮箱满时不阻塞，阻塞的策略按OverflowFail处理，放入后等待结果，超时由选项callTimeout决定
+func (g *gpcBase) TryCall(methodName string, param interface{}, result interface{}) error {
+	if result == nil {
+		panic("gpc: Call result param cant be nil")
+	}
+	ctx := context.Background()
+	d := &data{
+		ctx:    ctx,
+		method: methodName,
+		param:  param,
+		try:    true,
+	}
+	return g.callData(ctx, d, result, g.options.callTimeout).Wait()
+}
@@ -0,0 +1,157 @@
+package gpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// 处理block时阻塞直到release，record记录处理的参数
+type blockedActor struct {
+	*GPCFast
+	started  chan struct{}
+	release  chan struct{}
+	recorded chan int
+}
+
+func newBlockedActor(options ...GPCOption) *blockedActor {
+	a := &blockedActor{
+		started:  make(chan struct{}),
+		release:  make(chan struct{}),
+		recorded: make(chan int, 100),
+	}
+	handler := NewHandler()
+	handler.RegisterHandle("block", func(param interface{}, result interface{}) error {
+		close(a.started)
+		<-a.release
+		return nil
+	})
+	handler.RegisterHandle("record", func(param interface{}, result interface{}) error {
+		a.recorded <- param.(int)
+		return nil
+	})
+	a.GPCFast = NewGPCFast(handler, append([]GPCOption{ChannelLen(2)}, options...)...)
+	go a.Run()
+	a.Go("block", nil)
+	<-a.started
+	return a
+}
+
+// 放开阻塞，等待处理完，返回处理的参数
+func (a *blockedActor) finish() []int {
+	close(a.release)
+	a.Stop(context.Background())
+	close(a.recorded)
+	var got []int
+	for n := range a.recorded {
+		got = append(got, n)
+	}
+	return got
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestOverflow(t *testing.T) {
+	var n int
+
+	a := newBlockedActor(Overflow(OverflowFail))
+	a.Go("record", 1)
+	a.Go("record", 2)
+	if err := a.GoContext(context.Background(), "record", 3); !errors.Is(err, ErrMailboxFull) {
+		t.Fatalf("fail: err = %v", err)
+	}
+	if err := a.Call("record", 4, &n); !errors.Is(err, ErrMailboxFull) {
+		t.Fatalf("fail: call err = %v", err)
+	}
+	if got := a.finish(); !equalInts(got, []int{1, 2}) {
+		t.Fatalf("fail: recorded %v", got)
+	}
+
+	a = newBlockedActor(BlockTimeout(20))
+	a.Go("record", 1)
+	a.Go("record", 2)
+	start := time.Now()
+	if err := a.GoContext(context.Background(), "record", 3); !errors.Is(err, ErrMailboxFull) || time.Since(start) < 20*time.Millisecond {
+		t.Fatalf("block timeout: err = %v after %v", err, time.Since(start))
+	}
+	a.finish()
+
+	a = newBlockedActor(Overflow(OverflowDropNewest))
+	a.Go("record", 1)
+	a.Go("record", 2)
+	if err := a.GoContext(context.Background(), "record", 3); err != nil {
+		t.Fatalf("drop newest: err = %v", err)
+	}
+	if err := a.Call("record", 4, &n); !errors.Is(err, ErrMailboxFull) {
+		t.Fatalf("drop newest: call err = %v", err)
+	}
+	if a.Dropped() != 2 {
+		t.Fatalf("drop newest: dropped %v", a.Dropped())
+	}
+	if got := a.finish(); !equalInts(got, []int{1, 2}) {
+		t.Fatalf("drop newest: recorded %v", got)
+	}
+
+	for _, mt := range mailboxTypes {
+		if mt.mailboxType == MailboxUnbounded {
+			continue
+		}
+		a = newBlockedActor(Overflow(OverflowDropOldest), WithMailbox(mt.mailboxType))
+		future := a.CallAsync("record", 1, &n)
+		a.Go("record", 2)
+		a.Go("record", 3)
+		if err := future.Wait(); !errors.Is(err, ErrMailboxFull) {
+			t.Fatalf("%v drop oldest: call err = %v", mt.name, err)
+		}
+		if got := a.finish(); !equalInts(got, []int{2, 3}) {
+			t.Fatalf("%v drop oldest: recorded %v", mt.name, got)
+		}
+	}
+
+	a = newBlockedActor(Overflow(OverflowShedLowPriority), WithMailbox(MailboxPriority))
+	a.GoPriority(PriorityHigh, "record", 1)
+	a.GoPriority(PriorityLow, "record", 2)
+	// 丢弃优先级低的2，新消息没有更低的可以丢弃时丢弃新消息
+	a.GoPriority(PriorityNormal, "record", 3)
+	a.GoPriority(PriorityLow, "record", 4)
+	if a.Dropped() != 2 {
+		t.Fatalf("shed: dropped %v", a.Dropped())
+	}
+	if got := a.finish(); !equalInts(got, []int{1, 3}) {
+		t.Fatalf("shed: recorded %v", got)
+	}
+	// 其他邮箱不能按优先级丢弃
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("shed: no panic without MailboxPriority")
+			}
+		}()
+		NewGPCFast(NewHandler(), Overflow(OverflowShedLowPriority))
+	}()
+
+	// 默认阻塞的策略下TryGo和TryCall也不阻塞
+	a = newBlockedActor()
+	a.Go("record", 1)
+	a.Go("record", 2)
+	if err := a.TryGo("record", 3); !errors.Is(err, ErrMailboxFull) {
+		t.Fatalf("try go: err = %v", err)
+	}
+	if err := a.TryCall("record", 3, &n); !errors.Is(err, ErrMailboxFull) {
+		t.Fatalf("try call: err = %v", err)
+	}
+	if got := a.finish(); !equalInts(got, []int{1, 2}) {
+		t.Fatalf("try: recorded %v", got)
+	}
+}
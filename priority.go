@@ -0,0 +1,77 @@
+package gpc
+
+import "context"
+
+// 消息的优先级，Run先处理系统通道中的消息，然后是高优先级的消息，最后是邮箱中的普通消息
+// 使用MailboxPriority时高优先级和普通的消息都放入邮箱，按优先级排序
+const (
+	PriorityLow    = -1 // 低优先级，只有MailboxPriority按优先级排序，其他邮箱与普通消息相同
+	PriorityNormal = 0  // 普通优先级，默认，使用方法注册时设置的优先级
+	PriorityHigh   = 1  // 高优先级，放入单独的高优先级通道，容量与邮箱相同，满时按OverflowPolicy处理
+	PrioritySystem = 2  // 系统消息，放入无界的系统通道，不会满，用于停止、查询、重新配置等紧急的控制消息
+)
+
+type priorityKey struct{}
+
+// 返回带有消息优先级的上下文，用于GoContext、CallContext和类型安全句柄的调用
+func WithPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// 消息的优先级，依次使用调用时指定的、ctx中的和方法注册时设置的，都没有时为PriorityNormal
+func (g *gpcBase) priorityOf(ctx context.Context, d *data) int {
+	if d.priority != PriorityNormal {
+		return d.priority
+	}
+	if priority, ok := ctx.Value(priorityKey{}).(int); ok && priority != PriorityNormal {
+		return priority
+	}
+	return g.priorities[d.method]
+}
+
+// 消息放入的通道
+func (g *gpcBase) laneOf(d *data) Mailbox {
+	if d.priority >= PrioritySystem {
+		return g.systemLane
+	}
+	if d.priority > PriorityNormal && g.highLane != nil {
+		return g.highLane
+	}
+	return g.mailbox
+}
+
+// 所有通道中待处理的消息数
+func (g *gpcBase) queued() int {
+	n := g.systemLane.Len() + g.mailbox.Len()
+	if g.highLane != nil {
+		n += g.highLane.Len()
+	}
+	return n
+}
+
+// 指定优先级的无返回值调用，priority为PriorityNormal时使用方法注册时设置的优先级，同GoContext(WithPriority(ctx, priority), ...)
+func (g *gpcBase) GoPriority(priority int, methodName string, param interface{}) error {
+	ctx := context.Background()
+	d := &data{
+		ctx:      ctx,
+		method:   methodName,
+		param:    param,
+		priority: priority,
+	}
+	return g.goData(ctx, d)
+}
+
+// 指定优先级的同步调用，超时由选项callTimeout决定，priority为PriorityNormal时使用方法注册时设置的优先级
+func (g *gpcBase) CallPriority(priority int, methodName string, param interface{}, result interface{}) error {
+	if result == nil {
+		panic("gpc: Call result param cant be nil")
+	}
+	ctx := context.Background()
+	d := &data{
+		ctx:      ctx,
+		method:   methodName,
+		param:    param,
+		priority: priority,
+	}
+	return g.callData(ctx, d, result, g.options.callTimeout).Wait()
+}
@@ -0,0 +1,133 @@
+package gpc
+
+import (
+	"context"
+	"testing"
+)
+
+type LaneProc struct {
+	started  chan struct{}
+	release  chan struct{}
+	recorded chan int
+}
+
+type LaneArgs struct {
+	N int
+}
+
+func (p *LaneProc) Block(arg *LaneArgs) error {
+	close(p.started)
+	<-p.release
+	return nil
+}
+
+func (p *LaneProc) Record(arg *LaneArgs) error {
+	p.recorded <- arg.N
+	return nil
+}
+
+func (p *LaneProc) Urgent(arg *LaneArgs) error {
+	p.recorded <- arg.N
+	return nil
+}
+
+func TestPriority(t *testing.T) {
+	// 系统消息先处理，然后是高优先级的消息，系统通道不受邮箱容量限制
+	a := newBlockedActor()
+	a.Go("record", 1)
+	a.Go("record", 2)
+	a.GoPriority(PriorityHigh, "record", 3)
+	a.GoPriority(PriorityHigh, "record", 4)
+	for n := 5; n <= 7; n++ {
+		if err := a.GoPriority(PrioritySystem, "record", n); err != nil {
+			t.Fatalf("system: err = %v", err)
+		}
+	}
+	if got := a.finish(); !equalInts(got, []int{5, 6, 7, 3, 4, 1, 2}) {
+		t.Fatalf("lanes: recorded %v", got)
+	}
+
+	// 优先级队列的邮箱中高优先级和普通的消息按优先级排序
+	a = newBlockedActor(WithMailbox(MailboxPriority))
+	a.GoPriority(PriorityLow, "record", 1)
+	a.GoPriority(PriorityHigh, "record", 2)
+	a.GoPriority(PrioritySystem, "record", 3)
+	if got := a.finish(); !equalInts(got, []int{3, 2, 1}) {
+		t.Fatalf("priority mailbox: recorded %v", got)
+	}
+
+	// 高优先级通道满时按溢出策略处理
+	a = newBlockedActor(Overflow(OverflowDropNewest))
+	a.Go("record", 1)
+	a.GoPriority(PriorityHigh, "record", 2)
+	a.GoPriority(PriorityHigh, "record", 3)
+	a.GoPriority(PriorityHigh, "record", 4)
+	if got := a.finish(); !equalInts(got, []int{2, 3, 1}) {
+		t.Fatalf("high lane overflow: recorded %v", got)
+	}
+	if a.Dropped() != 1 {
+		t.Fatalf("high lane overflow: dropped %v", a.Dropped())
+	}
+
+	// Handler注册时设置方法的优先级
+	handler := NewHandler()
+	release := make(chan struct{})
+	recorded := make(chan int, 10)
+	handler.RegisterHandle("block", func(param interface{}, result interface{}) error {
+		<-release
+		return nil
+	})
+	handler.RegisterHandle("record", func(param interface{}, result interface{}) error {
+		recorded <- param.(int)
+		return nil
+	})
+	handler.RegisterPriorityHandle("urgent", PrioritySystem, func(param interface{}, result interface{}) error {
+		recorded <- param.(int)
+		return nil
+	})
+	fast := NewGPCFast(handler, ChannelLen(2))
+	fast.Go("block", nil)
+	fast.Go("record", 1)
+	fast.Go("urgent", 2)
+	go fast.Run()
+	close(release)
+	fast.Stop(context.Background())
+	close(recorded)
+	var got []int
+	for n := range recorded {
+		got = append(got, n)
+	}
+	if !equalInts(got, []int{2, 1}) {
+		t.Fatalf("RegisterPriorityHandle: recorded %v", got)
+	}
+}
+
+func TestMethodPriority(t *testing.T) {
+	proc := &LaneProc{
+		started:  make(chan struct{}),
+		release:  make(chan struct{}),
+		recorded: make(chan int, 10),
+	}
+	g, err := NewGPC(proc, ChannelLen(2), MethodPriority("LaneProc.Urgent", PrioritySystem))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go g.Run()
+	g.Go("LaneProc.Block", &LaneArgs{})
+	<-proc.started
+	g.Go("LaneProc.Record", &LaneArgs{N: 1})
+	g.GoPriority(PriorityHigh, "LaneProc.Record", &LaneArgs{N: 2})
+	g.Go("LaneProc.Urgent", &LaneArgs{N: 3})
+	// 指定的优先级优先于注册时设置的
+	g.GoPriority(PriorityLow, "LaneProc.Urgent", &LaneArgs{N: 4})
+	close(proc.release)
+	g.Stop(context.Background())
+	close(proc.recorded)
+	var got []int
+	for n := range proc.recorded {
+		got = append(got, n)
+	}
+	if !equalInts(got, []int{3, 2, 1, 4}) {
+		t.Fatalf("recorded %v", got)
+	}
+}
@@ -0,0 +1,110 @@
+package gpc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 定时消息的句柄，到期后消息通过通道投递，在Run所在协程中执行
+type Timer struct {
+	g        *gpcBase
+	method   string
+	param    interface{}
+	interval time.Duration // 周期消息的间隔，一次性消息为0
+	locker   sync.Mutex
+	timer    ClockTimer
+	stopped  bool
+	pending  bool // 已投递还没执行，周期消息在上一次执行之前不再投递
+}
+
+// delay之后投递无返回值的调用
+func (g *gpcBase) GoAfter(delay time.Duration, methodName string, param interface{}) *Timer {
+	t := &Timer{g: g, method: methodName, param: param}
+	t.start(delay)
+	return t
+}
+
+// 在at时刻投递无返回值的调用，at已过去时立即投递
+func (g *gpcBase) GoAt(at time.Time, methodName string, param interface{}) *Timer {
+	return g.GoAfter(at.Sub(g.clock.Now()), methodName, param)
+}
+
+// 每隔interval投递一次无返回值的调用，上一次还没执行时跳过本次，直到Stop或gpc关闭
+func (g *gpcBase) Every(interval time.Duration, methodName string, param interface{}) *Timer {
+	if interval <= 0 {
+		panic("gpc: non-positive interval for Every")
+	}
+	t := &Timer{g: g, method: methodName, param: param, interval: interval}
+	t.start(interval)
+	return t
+}
+
+// 取消定时消息，已投递还没执行的消息也不再执行
+// 返回false表示一次性消息已经执行或定时消息已经取消
+func (t *Timer) Stop() bool {
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	if t.stopped {
+		return false
+	}
+	t.stopped = true
+	t.timer.Stop()
+	return true
+}
+
+func (t *Timer) start(delay time.Duration) {
+	t.locker.Lock()
+	if t.interval > 0 {
+		t.timer = t.g.clock.Every(t.interval, t.fire)
+	} else {
+		t.timer = t.g.clock.AfterFunc(delay, t.fire)
+	}
+	t.locker.Unlock()
+}
+
+// 到期时投递消息
+func (t *Timer) fire() {
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	if t.stopped {
+		return
+	}
+	select {
+	case <-t.g.doneChan:
+		t.stopped = true
+		t.timer.Stop()
+		return
+	default:
+	}
+	if t.interval > 0 && t.pending {
+		return
+	}
+	t.pending = true
+	d := &data{
+		ctx:    context.Background(),
+		method: t.method,
+		param:  t.param,
+	}
+	d.fn = func() {
+		if !t.deliver() {
+			return
+		}
+		t.g.goMethodFunc(d)
+	}
+	t.g.postData(d)
+}
+
+// 执行前检查是否已取消，一次性消息执行后结束
+func (t *Timer) deliver() bool {
+	t.locker.Lock()
+	defer t.locker.Unlock()
+	if t.stopped {
+		return false
+	}
+	t.pending = false
+	if t.interval == 0 {
+		t.stopped = true
+	}
+	return true
+}
@@ -0,0 +1,154 @@
+package gpc
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// 模拟中处理的一条消息
+type TraceEvent struct {
+	Step   int
+	Actor  string
+	Method string // 异步调用的回调为空
+	Sender string // 不是从gpc中发出时为空
+}
+
+func (e TraceEvent) String() string {
+	method := e.Method
+	if method == "" {
+		method = "<callback>"
+	}
+	if e.Sender == "" {
+		return fmt.Sprintf("%d %s.%s", e.Step, e.Actor, method)
+	}
+	return fmt.Sprintf("%d %s.%s <- %s", e.Step, e.Actor, method, e.Sender)
+}
+
+// 模拟中的gpc
+type simActor struct {
+	g        *gpcBase
+	finished bool
+}
+
+// 确定性的单协程模拟，多个gpc在调用Step的协程中运行，每一步用种子确定的随机数选择一个有消息的gpc处理一条消息
+// 同样的种子和同样的输入得到同样的处理顺序，出错的交错顺序可以用种子重放，Trace记录了处理顺序
+// 模拟中的gpc不能调用Run，处理函数中不能用Call同步调用模拟中的其他gpc，要用Go或Ask
+// 通道满时投递会在其他协程中等待，破坏确定性，通道长度要足够；关闭gpc要用Close，不能用Stop等待
+// 定时器函数不会被调用，定时消息和调用超时使用模拟的时钟，gpc创建时要用WithClock(sim.Clock())
+type Simulation struct {
+	seed   int64
+	rng    *rand.Rand
+	clock  *FakeClock
+	actors []*simActor
+	steps  int
+	trace  []TraceEvent
+}
+
+// 创建种子为seed的模拟
+func NewSimulation(seed int64) *Simulation {
+	return &Simulation{
+		seed:  seed,
+		rng:   rand.New(rand.NewSource(seed)),
+		clock: NewFakeClock(time.Unix(0, 0)),
+	}
+}
+
+// 随机数种子
+func (s *Simulation) Seed() int64 {
+	return s.seed
+}
+
+// 模拟的时钟，只在Advance时推进
+func (s *Simulation) Clock() *FakeClock {
+	return s.clock
+}
+
+// 加入模拟并调用OnStart，OnStart返回错误时gpc退出
+func (s *Simulation) Add(actor Actor) error {
+	g := actor.base()
+	a := &simActor{g: g}
+	s.actors = append(s.actors, a)
+	if !g.begin() {
+		g.end(nil, false)
+		g.exit()
+		a.finished = true
+		return g.stopReason
+	}
+	return nil
+}
+
+// 处理一条消息，没有gpc有消息时返回false
+func (s *Simulation) Step() bool {
+	var ready []*simActor
+	for _, a := range s.actors {
+		if a.finished {
+			continue
+		}
+		if a.g.isClosed() {
+			s.finish(a)
+			continue
+		}
+		if a.g.queued() > 0 {
+			ready = append(ready, a)
+		}
+	}
+	if len(ready) == 0 {
+		return false
+	}
+	a := ready[s.rng.Intn(len(ready))]
+	d := a.g.pop()
+	if d == nil {
+		// 发送方还没有写完
+		return true
+	}
+	s.steps++
+	event := TraceEvent{Step: s.steps, Actor: a.g.Name(), Method: d.method}
+	if d.sender != nil {
+		event.Sender = d.sender.base().Name()
+	}
+	s.trace = append(s.trace, event)
+	a.g.process(d)
+	// 监督策略停止了gpc
+	if a.g.stopped {
+		s.finish(a)
+	}
+	return true
+}
+
+// 一直处理到没有消息或者处理了maxSteps条，maxSteps<=0时不限制，返回处理的消息数
+func (s *Simulation) Run(maxSteps int) int {
+	n := 0
+	for (maxSteps <= 0 || n < maxSteps) && s.Step() {
+		n++
+	}
+	return n
+}
+
+// 推进模拟的时钟，到期的定时消息和调用超时依次执行，之后要调用Step或Run处理投递的消息
+func (s *Simulation) Advance(d time.Duration) {
+	s.clock.Advance(d)
+}
+
+// 已处理消息的记录
+func (s *Simulation) Trace() []TraceEvent {
+	return s.trace
+}
+
+// 关闭所有gpc，按各自的选项处理剩余的消息
+func (s *Simulation) Stop() {
+	for _, a := range s.actors {
+		if !a.finished {
+			a.g.Close()
+			s.finish(a)
+		}
+	}
+}
+
+// 结束gpc，和Run退出时一样
+func (s *Simulation) finish(a *simActor) {
+	a.finished = true
+	a.g.stopped = true
+	a.g.end(nil, true)
+	a.g.exit()
+}
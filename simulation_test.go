@@ -0,0 +1,77 @@
+package gpc
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 两个客户端交错地向同一个服务添加和删除，记录服务看到的顺序
+func runSimulation(t *testing.T, seed int64) ([]TraceEvent, []string) {
+	sim := NewSimulation(seed)
+	var seen []string
+	store := NewHandler()
+	store.RegisterHandle("add", func(param interface{}, result interface{}) error {
+		seen = append(seen, "add:"+param.(string))
+		return nil
+	})
+	store.RegisterHandle("remove", func(param interface{}, result interface{}) error {
+		seen = append(seen, "remove:"+param.(string))
+		return nil
+	})
+	storeGpc := NewGPCFast(store, Name("store"), WithClock(sim.Clock()))
+	if err := sim.Add(storeGpc); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"c1", "c2"} {
+		name := name
+		client := NewHandler()
+		var c *GPCFast
+		client.RegisterHandle("start", func(param interface{}, result interface{}) error {
+			for i := 0; i < 3; i++ {
+				id := fmt.Sprintf("%v-%v", name, i)
+				// 等添加完成后再删除
+				c.Ask(storeGpc, "add", id, new(int), func(err error) {
+					c.Go("remove", id)
+				})
+			}
+			return nil
+		})
+		client.RegisterHandle("remove", func(param interface{}, result interface{}) error {
+			storeGpc.Go("remove", param)
+			return nil
+		})
+		c = NewGPCFast(client, Name(name), WithClock(sim.Clock()))
+		sim.Add(c)
+		c.GoAfter(time.Second, "start", nil)
+	}
+	sim.Run(0)
+	if len(sim.Trace()) != 0 {
+		t.Fatalf("processed %v before clock advanced", sim.Trace())
+	}
+	sim.Advance(time.Second)
+	sim.Run(0)
+	sim.Stop()
+	return sim.Trace(), seen
+}
+
+func TestSimulation(t *testing.T) {
+	trace, seen := runSimulation(t, 1)
+	if len(seen) != 12 {
+		t.Fatalf("seen %v", seen)
+	}
+	// 同样的种子得到同样的顺序
+	trace2, seen2 := runSimulation(t, 1)
+	if !reflect.DeepEqual(trace, trace2) || !reflect.DeepEqual(seen, seen2) {
+		t.Fatalf("replay differs:\n%v\n%v", trace, trace2)
+	}
+	differ := false
+	for seed := int64(2); seed < 10 && !differ; seed++ {
+		_, seen3 := runSimulation(t, seed)
+		differ = !reflect.DeepEqual(seen, seen3)
+	}
+	if !differ {
+		t.Fatalf("all seeds give the same interleaving %v", seen)
+	}
+}
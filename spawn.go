@@ -0,0 +1,93 @@
+package gpc
+
+import (
+	"context"
+	"log"
+)
+
+// 创建子gpc并运行，子gpc的Parent为本gpc，本gpc已关闭时返回ErrClosed
+// 本gpc监视子gpc，子gpc退出时收到Terminated，Reason不为nil表示子gpc失败，没有设置处理函数时记录日志
+// 本gpc退出时，处理完剩余的消息后按创建的逆序停止子gpc，子gpc都退出后再调用OnStop并退出
+func (g *gpcBase) Spawn(child Actor) error {
+	c := child.base()
+	g.childLocker.Lock()
+	if g.childrenStopped || g.isClosed() {
+		g.childLocker.Unlock()
+		return ErrClosed
+	}
+	c.parent = g
+	g.children = append(g.children, c)
+	g.childLocker.Unlock()
+	g.Watch(child)
+	c.onExit(func(reason error) {
+		g.removeChild(c)
+	})
+	go child.Run()
+	return nil
+}
+
+// 父gpc，不是由Spawn创建时返回nil
+func (g *gpcBase) Parent() Actor {
+	if g.parent == nil {
+		return nil
+	}
+	return g.parent.self
+}
+
+// 还在运行的子gpc，按创建的顺序
+func (g *gpcBase) Children() []Actor {
+	g.childLocker.Lock()
+	defer g.childLocker.Unlock()
+	children := make([]Actor, len(g.children))
+	for i, c := range g.children {
+		children[i] = c.self
+	}
+	return children
+}
+
+func (g *gpcBase) removeChild(c *gpcBase) {
+	g.childLocker.Lock()
+	defer g.childLocker.Unlock()
+	for i, child := range g.children {
+		if child == c {
+			g.children = append(g.children[:i], g.children[i+1:]...)
+			return
+		}
+	}
+}
+
+// 是否有还在运行的子gpc
+func (g *gpcBase) hasChildren() bool {
+	g.childLocker.Lock()
+	defer g.childLocker.Unlock()
+	return len(g.children) > 0
+}
+
+// 按创建的逆序停止子gpc并等待退出，之后不能再创建子gpc，本gpc放弃处理剩余消息时子gpc也放弃
+func (g *gpcBase) stopChildren() {
+	g.childLocker.Lock()
+	g.childrenStopped = true
+	children := make([]*gpcBase, len(g.children))
+	copy(children, g.children)
+	g.childLocker.Unlock()
+	if len(children) == 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-g.abortChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	for i := len(children) - 1; i >= 0; i-- {
+		children[i].Stop(ctx)
+	}
+}
+
+// 子gpc失败时父gpc没有处理Terminated，记录日志
+func (g *gpcBase) logChildFailure(c *gpcBase, reason error) {
+	log.Printf("gpc: %v child %v failed: %v", g.Name(), c.Name(), reason)
+}
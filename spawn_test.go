@@ -0,0 +1,106 @@
+package gpc
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// 停止顺序的记录
+type stopLog struct {
+	locker sync.Mutex
+	order  []string
+}
+
+func (l *stopLog) add(name string) {
+	l.locker.Lock()
+	l.order = append(l.order, name)
+	l.locker.Unlock()
+}
+
+func (l *stopLog) get() []string {
+	l.locker.Lock()
+	defer l.locker.Unlock()
+	return append([]string{}, l.order...)
+}
+
+type TreeProc struct {
+	log        *stopLog
+	options    []GPCOption
+	terminated chan Terminated
+}
+
+func (p *TreeProc) Join(c *Context, name string, reply *Actor) error {
+	handler := newCounterHandler()
+	handler.SetStopHandle(func(reason error) {
+		p.log.add(name)
+	})
+	options := append([]GPCOption{Name(name), Supervise(func(*PanicError) Directive {
+		return Stop
+	})}, p.options...)
+	child := NewGPCFast(handler, options...)
+	*reply = child
+	return c.Spawn(child)
+}
+
+func (p *TreeProc) OnTerminated(t Terminated) {
+	p.terminated <- t
+}
+
+func (p *TreeProc) OnStop(reason error) {
+	p.log.add("parent")
+}
+
+func testSpawn(t *testing.T, options ...GPCOption) {
+	proc := &TreeProc{log: &stopLog{}, options: options, terminated: make(chan Terminated, 10)}
+	parent, err := NewGPC(proc, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go parent.Run()
+
+	children := make(map[string]Actor)
+	for _, name := range []string{"a", "b", "c"} {
+		var child Actor
+		if err := parent.Call("TreeProc.Join", name, &child); err != nil {
+			t.Fatal(err)
+		}
+		if child.base().Parent() != parent {
+			t.Fatalf("parent of %v is %v", name, child.base().Parent())
+		}
+		children[name] = child
+	}
+
+	// 子gpc失败时通知父gpc
+	var n int
+	children["b"].Call("boom", nil, &n)
+	term := <-proc.terminated
+	if term.Actor != children["b"] || !errors.Is(term.Reason, ErrPanic) {
+		t.Fatalf("terminated %v %v", term.Actor, term.Reason)
+	}
+	<-children["b"].Done()
+	if got := parent.Children(); len(got) != 2 || got[0] != children["a"] || got[1] != children["c"] {
+		t.Fatalf("children %v", got)
+	}
+
+	// 先按创建的逆序停止子gpc
+	parent.Stop(context.Background())
+	want := []string{"b", "c", "a", "parent"}
+	if got := proc.log.get(); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] || got[3] != want[3] {
+		t.Fatalf("stop order %v, want %v", got, want)
+	}
+	if err := parent.Spawn(NewGPCFast(newCounterHandler())); err != ErrClosed {
+		t.Fatalf("spawn after stop err = %v", err)
+	}
+}
+
+func TestSpawn(t *testing.T) {
+	testSpawn(t)
+}
+
+func TestSpawnExecutor(t *testing.T) {
+	exec := NewExecutor(1)
+	defer exec.Stop()
+	testSpawn(t, RunOn(exec))
+}
@@ -0,0 +1,109 @@
+package gpc
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+)
+
+// 处理函数panic时返回给调用方的错误
+type PanicError struct {
+	Method string      // panic的方法
+	Value  interface{} // recover得到的值
+	Stack  []byte      // panic时的调用栈
+}
+
+func newPanicError(method string, value interface{}) *PanicError {
+	return &PanicError{
+		Method: method,
+		Value:  value,
+		Stack:  debug.Stack(),
+	}
+}
+
+// 处理函数panic时得到的错误，开启PanicOnDeadlock时检测到死锁的panic继续向上传递
+func (g *gpcBase) recovered(method string, value interface{}) *PanicError {
+	if _, ok := value.(*DeadlockError); ok && g.options.panicOnDeadlock {
+		panic(value)
+	}
+	return newPanicError(method, value)
+}
+
+func (e *PanicError) Is(target error) bool {
+	return target == ErrPanic
+}
+
+// panic的值是error时返回它，可以用errors.Is和errors.As判断
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("gpc: method %v panic: %v\n%s", e.Method, e.Value, e.Stack)
+}
+
+// 监督指令，处理函数panic后决定gpc如何继续
+type Directive int
+
+const (
+	Resume  Directive = iota // 保留服务的状态，继续处理后面的消息
+	Restart                  // 通过工厂重新创建服务，再继续处理后面的消息
+	Stop                     // 停止Run
+)
+
+func (d Directive) String() string {
+	switch d {
+	case Resume:
+		return "resume"
+	case Restart:
+		return "restart"
+	case Stop:
+		return "stop"
+	}
+	return fmt.Sprintf("Directive(%d)", int(d))
+}
+
+// 监督策略，根据panic返回监督指令
+type SupervisorStrategy func(err *PanicError) Directive
+
+// 监督钩子，每次执行监督指令后调用，可用于日志和统计
+type SupervisorHook func(directive Directive, err *PanicError)
+
+var errNoFactory = errors.New("gpc: restart without factory")
+
+// 执行监督策略，默认为Resume，重新创建服务失败时停止
+func (g *gpcBase) supervise(err *PanicError) {
+	directive := Resume
+	if g.options.supervisor != nil {
+		directive = g.options.supervisor(err)
+	}
+	var reason error = err
+	if directive == Restart {
+		rerr := g.restart()
+		if rerr == nil {
+			rerr = g.callOnRestart(err)
+		}
+		if rerr != nil {
+			log.Printf("gpc: %v restart failed: %v", g.Name(), rerr)
+			directive = Stop
+			reason = rerr
+		}
+	}
+	if directive == Stop {
+		g.stopped = true
+		g.stopReason = reason
+	}
+	if g.options.supervisorHook != nil {
+		g.options.supervisorHook(directive, err)
+	}
+}
+
+// 通过工厂重新创建服务
+func (g *gpcBase) restart() error {
+	if g.options.factory == nil || g.restartFunc == nil {
+		return errNoFactory
+	}
+	return g.restartFunc()
+}
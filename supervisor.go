@@ -0,0 +1,412 @@
+package gpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	GPC_SUPERVISOR_MAX_RESTARTS = 3    // 默认重启强度的最大重启次数
+	GPC_SUPERVISOR_WITHIN_MS    = 5000 // 默认重启强度的时间窗口
+	GPC_SUPERVISOR_STOP_MS      = 5000 // 默认停止每个子节点最多等待的时间，超时后放弃子gpc剩余的消息
+)
+
+// 子节点的重启策略
+type RestartStrategy int
+
+const (
+	OneForOne  RestartStrategy = iota // 只重启失败的子节点
+	OneForAll                         // 重启所有子节点
+	RestForOne                        // 重启失败的子节点和在它之后启动的子节点
+)
+
+// 子gpc的规格
+type ChildSpec struct {
+	Name    string
+	Factory func() (Actor, error) // 创建子gpc，启动和每次重启时调用
+}
+
+// 被监督的对象，子gpc或者子监督者
+type supervised interface {
+	start() error
+	stop(ctx context.Context)
+	onExit(hook func(reason error))
+}
+
+// 被监督的gpc
+type supervisedActor struct {
+	actor Actor
+}
+
+func (a supervisedActor) start() error {
+	go a.actor.Run()
+	return nil
+}
+
+func (a supervisedActor) stop(ctx context.Context) {
+	a.actor.Stop(ctx)
+}
+
+func (a supervisedActor) onExit(hook func(reason error)) {
+	a.actor.base().onExit(hook)
+}
+
+// 监督者的子节点
+type child struct {
+	name    string
+	factory func() (Actor, error)
+	sub     *Supervisor
+	actor   Actor
+	node    supervised
+	gen     int // 每次启动和主动停止时增加，用于忽略过期的退出通知
+	running bool
+}
+
+// 子节点退出的通知
+type childExit struct {
+	c      *child
+	gen    int
+	reason error
+}
+
+// 监督者，按重启策略管理一组子gpc或子监督者
+// 子节点以非nil的原因退出时重启，正常关闭的子节点不重启
+// 重启次数超过重启强度时停止所有子节点并以ErrMaxRestarts退出，作为子监督者时由上级监督者处理
+type Supervisor struct {
+	name        string
+	strategy    RestartStrategy
+	maxRestarts int
+	within      time.Duration
+	stopTimeout time.Duration
+	locker      sync.Mutex
+	children    []*child
+	restarts    []time.Time
+	running     bool
+	started     bool
+	exitChan    chan childExit
+	quitChan    chan struct{}
+	doneChan    chan struct{}
+	err         error
+	exitHooks   []func(reason error)
+}
+
+type SupervisorOption func(*Supervisor)
+
+// 停止每个子节点最多等待的时间，超时后放弃子gpc剩余的消息
+func StopTimeout(timeout time.Duration) SupervisorOption {
+	return func(s *Supervisor) {
+		s.stopTimeout = timeout
+	}
+}
+
+// 重启强度，within时间内重启超过maxRestarts次时监督者失败
+func MaxRestarts(maxRestarts int, within time.Duration) SupervisorOption {
+	return func(s *Supervisor) {
+		s.maxRestarts = maxRestarts
+		s.within = within
+	}
+}
+
+// 创建监督者
+func NewSupervisor(name string, strategy RestartStrategy, options ...SupervisorOption) *Supervisor {
+	s := &Supervisor{
+		name:        name,
+		strategy:    strategy,
+		maxRestarts: GPC_SUPERVISOR_MAX_RESTARTS,
+		within:      GPC_SUPERVISOR_WITHIN_MS * time.Millisecond,
+		stopTimeout: GPC_SUPERVISOR_STOP_MS * time.Millisecond,
+		doneChan:    make(chan struct{}),
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// 监督者的名字
+func (s *Supervisor) Name() string {
+	return s.name
+}
+
+// 添加子gpc，监督者已启动时立即启动
+// 没有设置Supervise选项的子gpc在处理函数panic时退出，交给监督者重启
+func (s *Supervisor) AddChild(spec ChildSpec) error {
+	if spec.Factory == nil {
+		return fmt.Errorf("gpc: supervisor %v child %v has no factory", s.name, spec.Name)
+	}
+	return s.add(&child{name: spec.Name, factory: spec.Factory})
+}
+
+// 添加子监督者，子监督者失败时按本监督者的策略重启
+func (s *Supervisor) AddSupervisor(sub *Supervisor) error {
+	return s.add(&child{name: sub.name, sub: sub})
+}
+
+func (s *Supervisor) add(c *child) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	for _, old := range s.children {
+		if old.name == c.name {
+			return fmt.Errorf("gpc: supervisor %v child %v already exists", s.name, c.name)
+		}
+	}
+	if s.running {
+		if err := s.startChild(c); err != nil {
+			return err
+		}
+	}
+	s.children = append(s.children, c)
+	return nil
+}
+
+// 获得子gpc，子监督者或不存在时返回nil
+func (s *Supervisor) Child(name string) Actor {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	for _, c := range s.children {
+		if c.name == name {
+			return c.actor
+		}
+	}
+	return nil
+}
+
+// 按添加的顺序启动所有子节点，有子节点启动失败时停止已启动的子节点并返回错误
+func (s *Supervisor) Start() error {
+	return s.start()
+}
+
+func (s *Supervisor) start() error {
+	s.locker.Lock()
+	if s.running {
+		s.locker.Unlock()
+		return nil
+	}
+	s.exitChan = make(chan childExit, len(s.children)+1)
+	s.quitChan = make(chan struct{})
+	for i, c := range s.children {
+		if err := s.startChild(c); err != nil {
+			nodes := s.detachChildren(s.children[:i])
+			close(s.quitChan)
+			s.locker.Unlock()
+			s.stopNodes(context.Background(), nodes)
+			return err
+		}
+	}
+	s.running = true
+	s.err = nil
+	s.restarts = nil
+	// 作为子监督者重启时重新创建
+	if s.started {
+		s.doneChan = make(chan struct{})
+	}
+	s.started = true
+	go s.loop(s.exitChan, s.quitChan)
+	s.locker.Unlock()
+	return nil
+}
+
+// 按启动的逆序停止所有子节点
+// 每个子节点最多等待StopTimeout设置的时间，超时后放弃子gpc剩余的消息
+func (s *Supervisor) Stop() {
+	s.stop(context.Background())
+}
+
+func (s *Supervisor) stop(ctx context.Context) {
+	s.locker.Lock()
+	if !s.running {
+		s.locker.Unlock()
+		return
+	}
+	s.shutdown(ctx, nil)
+}
+
+// 停止后关闭的通道
+func (s *Supervisor) Done() <-chan struct{} {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return s.doneChan
+}
+
+// 停止的原因，正常停止为nil
+func (s *Supervisor) Err() error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	return s.err
+}
+
+// 添加停止时调用的钩子，只调用一次
+func (s *Supervisor) onExit(hook func(reason error)) {
+	s.locker.Lock()
+	if s.running {
+		s.exitHooks = append(s.exitHooks, hook)
+		s.locker.Unlock()
+		return
+	}
+	err := s.err
+	s.locker.Unlock()
+	hook(err)
+}
+
+// 处理子节点的退出通知
+func (s *Supervisor) loop(exitChan chan childExit, quit chan struct{}) {
+	for {
+		select {
+		case ev := <-exitChan:
+			s.handleExit(ev)
+		case <-quit:
+			return
+		}
+	}
+}
+
+func (s *Supervisor) handleExit(ev childExit) {
+	s.locker.Lock()
+	if !s.running || ev.gen != ev.c.gen {
+		s.locker.Unlock()
+		return
+	}
+	ev.c.running = false
+	if ev.reason == nil {
+		s.locker.Unlock()
+		return
+	}
+	if !s.allowRestart() {
+		s.shutdown(context.Background(), fmt.Errorf("%w: %v: %v", ErrMaxRestarts, s.name, ev.reason))
+		return
+	}
+
+	i := 0
+	for s.children[i] != ev.c {
+		i++
+	}
+	var restart []*child
+	switch s.strategy {
+	case OneForOne:
+		restart = s.children[i : i+1]
+	case OneForAll:
+		restart = s.children
+	case RestForOne:
+		restart = s.children[i:]
+	}
+	restart = append([]*child(nil), restart...)
+	// 先按启动的逆序停止，再按顺序重新启动
+	// 在锁外停止，子gpc处理剩余消息时可以调用Child等方法
+	nodes := s.detachChildren(restart)
+	s.locker.Unlock()
+	s.stopNodes(context.Background(), nodes)
+	s.locker.Lock()
+	if !s.running {
+		// 停止子节点时监督者被停止了
+		s.locker.Unlock()
+		return
+	}
+	for _, c := range restart {
+		if err := s.startChild(c); err != nil {
+			s.shutdown(context.Background(), fmt.Errorf("gpc: supervisor %v restart child %v: %w", s.name, c.name, err))
+			return
+		}
+	}
+	s.locker.Unlock()
+}
+
+// 检查重启强度并记录一次重启
+func (s *Supervisor) allowRestart() bool {
+	now := time.Now()
+	n := 0
+	for _, t := range s.restarts {
+		if now.Sub(t) < s.within {
+			s.restarts[n] = t
+			n++
+		}
+	}
+	s.restarts = s.restarts[:n]
+	if n >= s.maxRestarts {
+		return false
+	}
+	s.restarts = append(s.restarts, now)
+	return true
+}
+
+// 启动子节点，调用时要持有锁
+func (s *Supervisor) startChild(c *child) error {
+	c.gen++
+	if c.sub != nil {
+		c.node = c.sub
+	} else {
+		actor, err := c.factory()
+		if err != nil {
+			return err
+		}
+		if b := actor.base(); b.options.supervisor == nil {
+			b.options.supervisor = func(*PanicError) Directive {
+				return Stop
+			}
+		}
+		c.actor = actor
+		c.node = supervisedActor{actor: actor}
+	}
+	if err := c.node.start(); err != nil {
+		return err
+	}
+	c.running = true
+	gen, exitChan, quit := c.gen, s.exitChan, s.quitChan
+	c.node.onExit(func(reason error) {
+		ev := childExit{c: c, gen: gen, reason: reason}
+		// 不阻塞退出的子节点，监督者正在停止其他子节点时可能暂时不能接收
+		select {
+		case exitChan <- ev:
+		case <-quit:
+		default:
+			go func() {
+				select {
+				case exitChan <- ev:
+				case <-quit:
+				}
+			}()
+		}
+	})
+	return nil
+}
+
+// 标记子节点已停止，按逆序返回还在运行的子节点，调用时要持有锁
+func (s *Supervisor) detachChildren(children []*child) []supervised {
+	var nodes []supervised
+	for i := len(children) - 1; i >= 0; i-- {
+		c := children[i]
+		c.gen++
+		if c.running {
+			c.running = false
+			nodes = append(nodes, c.node)
+		}
+	}
+	return nodes
+}
+
+// 依次停止子节点，调用时不能持有锁，每个子节点最多等待stopTimeout
+func (s *Supervisor) stopNodes(ctx context.Context, nodes []supervised) {
+	for _, node := range nodes {
+		stopCtx, cancel := context.WithTimeout(ctx, s.stopTimeout)
+		node.stop(stopCtx)
+		cancel()
+	}
+}
+
+// 停止所有子节点并结束运行，调用时要持有锁，返回前释放
+func (s *Supervisor) shutdown(ctx context.Context, err error) {
+	nodes := s.detachChildren(s.children)
+	s.running = false
+	s.err = err
+	close(s.quitChan)
+	done := s.doneChan
+	hooks := s.exitHooks
+	s.exitHooks = nil
+	s.locker.Unlock()
+	s.stopNodes(ctx, nodes)
+	close(done)
+	for _, hook := range hooks {
+		hook(err)
+	}
+}
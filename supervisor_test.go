@@ -0,0 +1,180 @@
+package gpc
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// 等待监督者重启子gpc
+func waitRestart(t *testing.T, s *Supervisor, name string, old Actor) Actor {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if a := s.Child(name); a != nil && a != old {
+			return a
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("child %v not restarted", name)
+	return nil
+}
+
+func counterSpec(name string) ChildSpec {
+	return ChildSpec{
+		Name: name,
+		Factory: func() (Actor, error) {
+			return NewGPCFast(newCounterHandler(), Name(name)), nil
+		},
+	}
+}
+
+func TestSupervisorStrategy(t *testing.T) {
+	for _, c := range []struct {
+		strategy  RestartStrategy
+		restarted []bool
+	}{
+		{OneForOne, []bool{false, true, false}},
+		{OneForAll, []bool{true, true, true}},
+		{RestForOne, []bool{false, true, true}},
+	} {
+		s := NewSupervisor("root", c.strategy)
+		names := []string{"a", "b", "c"}
+		for _, name := range names {
+			if err := s.AddChild(counterSpec(name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.Start(); err != nil {
+			t.Fatal(err)
+		}
+		var old []Actor
+		for _, name := range names {
+			old = append(old, s.Child(name))
+		}
+		var n int
+		old[1].Call("boom", nil, &n)
+		waitRestart(t, s, "b", old[1])
+		for i, name := range names {
+			if restarted := s.Child(name) != old[i]; restarted != c.restarted[i] {
+				t.Errorf("strategy %v child %v restarted = %v", c.strategy, name, restarted)
+			}
+		}
+		s.Stop()
+		for i, name := range names {
+			select {
+			case <-s.Child(name).base().doneChan:
+			default:
+				t.Errorf("child %v not stopped", names[i])
+			}
+		}
+	}
+}
+
+func TestSupervisorEscalate(t *testing.T) {
+	root := NewSupervisor("root", OneForOne)
+	sub := NewSupervisor("sub", OneForOne, MaxRestarts(1, time.Minute))
+	sub.AddChild(counterSpec("a"))
+	root.AddSupervisor(sub)
+	if err := root.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	a := sub.Child("a")
+	a.Call("boom", nil, &n)
+	a = waitRestart(t, sub, "a", a)
+	// 超过重启强度，子监督者失败后由上级重启
+	subDone := sub.Done()
+	a.Call("boom", nil, &n)
+	<-subDone
+	a = waitRestart(t, sub, "a", a)
+	if err := a.Call("incr", nil, &n); err != nil || n != 1 {
+		t.Errorf("after escalation n = %v, err = %v", n, err)
+	}
+
+	root.Stop()
+	select {
+	case <-root.Done():
+	default:
+		t.Error("root not stopped")
+	}
+	if err := root.Err(); err != nil {
+		t.Errorf("root err = %v", err)
+	}
+
+	top := NewSupervisor("top", OneForOne, MaxRestarts(0, time.Minute))
+	top.AddChild(counterSpec("a"))
+	top.Start()
+	top.Child("a").Call("boom", nil, &n)
+	<-top.Done()
+	if err := top.Err(); !errors.Is(err, ErrMaxRestarts) {
+		t.Errorf("top err = %v", err)
+	}
+}
+
+func TestSupervisorStopUnlocked(t *testing.T) {
+	// 停止子gpc时处理剩余的消息可以查找兄弟节点
+	s := NewSupervisor("root", OneForAll)
+	release := make(chan struct{})
+	found := make(chan Actor, 1)
+	s.AddChild(ChildSpec{
+		Name: "a",
+		Factory: func() (Actor, error) {
+			handler := NewHandler()
+			handler.RegisterHandle("block", func(param interface{}, result interface{}) error {
+				<-release
+				return nil
+			})
+			handler.RegisterHandle("lookup", func(param interface{}, result interface{}) error {
+				found <- s.Child("b")
+				return nil
+			})
+			return NewGPCFast(handler, Name("a")), nil
+		},
+	})
+	s.AddChild(counterSpec("b"))
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	a, b := s.Child("a"), s.Child("b")
+	a.Go("block", nil)
+	a.Go("lookup", nil)
+	var n int
+	b.Call("boom", nil, &n)
+	time.AfterFunc(10*time.Millisecond, func() {
+		close(release)
+	})
+	waitRestart(t, s, "a", a)
+	if <-found != b {
+		t.Error("lookup did not find b")
+	}
+	s.Stop()
+
+	// 处理函数一直不返回的子gpc不会让监督者一直阻塞
+	hang := make(chan struct{})
+	defer close(hang)
+	s = NewSupervisor("root", OneForOne, StopTimeout(20*time.Millisecond))
+	s.AddChild(ChildSpec{
+		Name: "hang",
+		Factory: func() (Actor, error) {
+			handler := NewHandler()
+			handler.RegisterHandle("hang", func(param interface{}, result interface{}) error {
+				<-hang
+				return nil
+			})
+			return NewGPCFast(handler, Name("hang")), nil
+		},
+	})
+	s.Start()
+	s.Child("hang").Go("hang", nil)
+	stopped := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop blocked by a hung child")
+	}
+}
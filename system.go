@@ -0,0 +1,173 @@
+package gpc
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 名字树的节点，每一段路径一个节点
+type pathNode struct {
+	children map[string]*pathNode
+	actor    Actor
+}
+
+// gpc系统，用路径形式的名字注册和查找gpc，如"/guilds/42"
+// gpc退出时自动从系统中移除，Select可以用通配符选择一组gpc，如"/guilds/*"
+type System struct {
+	locker sync.RWMutex
+	root   *pathNode
+}
+
+// 创建系统
+func NewSystem() *System {
+	return &System{root: &pathNode{}}
+}
+
+// 拆分路径，路径要以/开头，每一段都不能为空
+func splitPath(name string) ([]string, bool) {
+	if !strings.HasPrefix(name, "/") {
+		return nil, false
+	}
+	segments := strings.Split(name[1:], "/")
+	for _, seg := range segments {
+		if seg == "" {
+			return nil, false
+		}
+	}
+	return segments, true
+}
+
+// 用name注册gpc，名字中不能有通配符，已被其他gpc使用时返回ErrNameTaken
+// gpc退出后自动注销
+func (s *System) Register(name string, actor Actor) error {
+	segments, ok := splitPath(name)
+	if !ok || strings.ContainsAny(name, `*?[\`) {
+		return ErrBadPath
+	}
+	s.locker.Lock()
+	node := s.root
+	for _, seg := range segments {
+		child, ok := node.children[seg]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[string]*pathNode)
+			}
+			child = &pathNode{}
+			node.children[seg] = child
+		}
+		node = child
+	}
+	if node.actor != nil {
+		same := node.actor == actor
+		s.locker.Unlock()
+		if same {
+			return nil
+		}
+		return ErrNameTaken
+	}
+	node.actor = actor
+	s.locker.Unlock()
+	actor.base().onExit(func(reason error) {
+		s.unregister(segments, actor)
+	})
+	return nil
+}
+
+// 注销name，返回是否注册过
+func (s *System) Unregister(name string) bool {
+	segments, ok := splitPath(name)
+	if !ok {
+		return false
+	}
+	return s.unregister(segments, nil)
+}
+
+// 注销路径上的gpc，actor不为nil时只注销这个gpc，并删除空的节点
+func (s *System) unregister(segments []string, actor Actor) bool {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+	nodes := make([]*pathNode, 0, len(segments)+1)
+	node := s.root
+	nodes = append(nodes, node)
+	for _, seg := range segments {
+		if node = node.children[seg]; node == nil {
+			return false
+		}
+		nodes = append(nodes, node)
+	}
+	if node.actor == nil || (actor != nil && node.actor != actor) {
+		return false
+	}
+	node.actor = nil
+	for i := len(segments) - 1; i >= 0; i-- {
+		if n := nodes[i+1]; n.actor != nil || len(n.children) > 0 {
+			break
+		}
+		delete(nodes[i].children, segments[i])
+	}
+	return true
+}
+
+// 查找name注册的gpc
+func (s *System) Lookup(name string) (Actor, bool) {
+	segments, ok := splitPath(name)
+	if !ok {
+		return nil, false
+	}
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	node := s.root
+	for _, seg := range segments {
+		if node = node.children[seg]; node == nil {
+			return nil, false
+		}
+	}
+	return node.actor, node.actor != nil
+}
+
+// 选择名字与pattern匹配的gpc，按名字排序
+// pattern的每一段按path.Match匹配对应的一段，如"/guilds/*"匹配"/guilds/42"，不匹配"/guilds/42/members"
+func (s *System) Select(pattern string) []Actor {
+	segments, ok := splitPath(pattern)
+	if !ok {
+		return nil
+	}
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	var actors []Actor
+	s.root.match(segments, &actors)
+	return actors
+}
+
+func (n *pathNode) match(segments []string, actors *[]Actor) {
+	if len(segments) == 0 {
+		if n.actor != nil {
+			*actors = append(*actors, n.actor)
+		}
+		return
+	}
+	names := make([]string, 0, len(n.children))
+	for name := range n.children {
+		if ok, _ := path.Match(segments[0], name); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		n.children[name].match(segments[1:], actors)
+	}
+}
+
+// 向名字与pattern匹配的所有gpc投递无返回值调用，返回投递成功的数量
+func (s *System) Broadcast(pattern string, methodName string, param interface{}) int {
+	n := 0
+	for _, actor := range s.Select(pattern) {
+		if actor.GoContext(context.Background(), methodName, param) == nil {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,80 @@
+package gpc
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestSystem(t *testing.T) {
+	s := NewSystem()
+	notified := make(chan string, 10)
+	var guilds []*GPCFast
+	for i := 1; i <= 3; i++ {
+		name := fmt.Sprintf("guild%v", i)
+		handler := NewHandler()
+		handler.RegisterHandle("notify", func(param interface{}, result interface{}) error {
+			notified <- name
+			return nil
+		})
+		g := NewGPCFast(handler, Name(name))
+		go g.Run()
+		guilds = append(guilds, g)
+		if err := s.Register(fmt.Sprintf("/guilds/%v", i), g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	member := NewGPCFast(newCounterHandler())
+	go member.Run()
+	defer member.Close()
+	s.Register("/guilds/1/members/7", member)
+
+	if err := s.Register("/guilds/1", member); err != ErrNameTaken {
+		t.Fatalf("err = %v", err)
+	}
+	for _, name := range []string{"guilds", "/guilds//1", "/guilds/*"} {
+		if err := s.Register(name, member); err != ErrBadPath {
+			t.Fatalf("register %v err = %v", name, err)
+		}
+	}
+	if actor, ok := s.Lookup("/guilds/2"); !ok || actor != guilds[1] {
+		t.Fatalf("lookup /guilds/2 = %v", actor)
+	}
+	if _, ok := s.Lookup("/guilds"); ok {
+		t.Fatalf("lookup intermediate node")
+	}
+
+	// 通配符只匹配一段
+	selected := s.Select("/guilds/*")
+	if len(selected) != 3 || selected[0] != guilds[0] || selected[2] != guilds[2] {
+		t.Fatalf("selected %v", selected)
+	}
+	if selected = s.Select("/guilds/*/members/*"); len(selected) != 1 || selected[0] != member {
+		t.Fatalf("selected %v", selected)
+	}
+	if n := s.Broadcast("/guilds/[12]", "notify", nil); n != 2 {
+		t.Fatalf("broadcast to %v", n)
+	}
+	for i := 0; i < 2; i++ {
+		if name := <-notified; name == "guild3" {
+			t.Fatalf("%v notified", name)
+		}
+	}
+
+	// 退出后自动注销
+	guilds[1].Stop(context.Background())
+	if _, ok := s.Lookup("/guilds/2"); ok {
+		t.Fatalf("stopped actor still registered")
+	}
+	if !s.Unregister("/guilds/3") || s.Unregister("/guilds/3") {
+		t.Fatalf("unregister should succeed only once")
+	}
+	if selected = s.Select("/guilds/*"); len(selected) != 1 {
+		t.Fatalf("selected %v", selected)
+	}
+	guilds[0].Stop(context.Background())
+	guilds[2].Stop(context.Background())
+	if _, ok := s.Lookup("/guilds/1/members/7"); !ok {
+		t.Fatalf("child name removed with parent")
+	}
+}
@@ -0,0 +1,193 @@
+package gpc
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 组内的gpc
+type tickMember struct {
+	g     *gpcBase
+	frame int64 // 已完成的帧
+}
+
+// 用一个时钟同步驱动一组gpc的定时器函数
+// 所有成员完成第N帧之后才开始第N+1帧，每帧作为消息投递到成员的通道中，在成员的Run所在协程中执行
+// 成员实现了FrameService时调用FrameTick，否则调用Tick，dt固定为组的tickMs
+// 加入组的gpc不再按自己的tickMs调用定时器函数；成员退出时自动离开组，不会阻塞其他成员
+type TickGroup struct {
+	tickMs    int32
+	barrier   func(frame int64)
+	clock     Clock
+	locker    sync.Mutex
+	members   []*tickMember
+	frame     int64 // 当前帧
+	remaining int   // 当前帧还没完成的成员数
+	frameDone chan struct{}
+	running   bool
+	quitChan  chan struct{}
+	doneChan  chan struct{}
+}
+
+type TickGroupOption func(*TickGroup)
+
+// 每帧所有成员完成之后、下一帧开始之前调用，在组的协程中执行
+func Barrier(barrier func(frame int64)) TickGroupOption {
+	return func(tg *TickGroup) {
+		tg.barrier = barrier
+	}
+}
+
+// 驱动帧的时钟，默认为RealClock
+func GroupClock(clock Clock) TickGroupOption {
+	return func(tg *TickGroup) {
+		tg.clock = clock
+	}
+}
+
+// 创建帧间隔为tickMs的组
+func NewTickGroup(tickMs int32, options ...TickGroupOption) *TickGroup {
+	if tickMs <= 0 {
+		tickMs = GPC_TICK_MS
+	}
+	tg := &TickGroup{
+		tickMs:    tickMs,
+		frameDone: make(chan struct{}, 1),
+	}
+	for _, option := range options {
+		option(tg)
+	}
+	if tg.clock == nil {
+		tg.clock = RealClock(0)
+	}
+	return tg
+}
+
+// 加入组，从下一帧开始驱动
+func (tg *TickGroup) Add(actor Actor) {
+	m := &tickMember{g: actor.base()}
+	tg.locker.Lock()
+	for _, old := range tg.members {
+		if old.g == m.g {
+			tg.locker.Unlock()
+			return
+		}
+	}
+	// 当前帧开始之后加入的不参与当前帧
+	m.frame = tg.frame
+	tg.members = append(tg.members, m)
+	tg.locker.Unlock()
+	atomic.StoreInt32(&m.g.grouped, 1)
+	m.g.onExit(func(reason error) {
+		tg.remove(m.g)
+	})
+}
+
+// 离开组，之后按自己的tickMs调用定时器函数
+func (tg *TickGroup) Remove(actor Actor) {
+	if tg.remove(actor.base()) {
+		atomic.StoreInt32(&actor.base().grouped, 0)
+	}
+}
+
+func (tg *TickGroup) remove(g *gpcBase) bool {
+	tg.locker.Lock()
+	defer tg.locker.Unlock()
+	for i, m := range tg.members {
+		if m.g == g {
+			tg.members = append(tg.members[:i], tg.members[i+1:]...)
+			// 不再等待离开的成员
+			tg.finish(m, tg.frame)
+			return true
+		}
+	}
+	return false
+}
+
+// 当前帧号
+func (tg *TickGroup) Frame() int64 {
+	tg.locker.Lock()
+	defer tg.locker.Unlock()
+	return tg.frame
+}
+
+// 开始按tickMs驱动
+func (tg *TickGroup) Start() {
+	tg.locker.Lock()
+	defer tg.locker.Unlock()
+	if tg.running {
+		return
+	}
+	tg.running = true
+	tg.quitChan = make(chan struct{})
+	tg.doneChan = make(chan struct{})
+	go tg.loop(tg.quitChan, tg.doneChan)
+}
+
+// 停止驱动，等待当前帧完成
+func (tg *TickGroup) Stop() {
+	tg.locker.Lock()
+	if !tg.running {
+		tg.locker.Unlock()
+		return
+	}
+	tg.running = false
+	close(tg.quitChan)
+	done := tg.doneChan
+	tg.locker.Unlock()
+	<-done
+}
+
+func (tg *TickGroup) loop(quit, done chan struct{}) {
+	defer close(done)
+	tickChan, ticker := tg.clock.NewTicker(time.Duration(tg.tickMs) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-tickChan:
+			tg.step()
+		case <-quit:
+			return
+		}
+	}
+}
+
+// 执行一帧，所有成员完成后调用barrier
+func (tg *TickGroup) step() {
+	tg.locker.Lock()
+	tg.frame++
+	frame := tg.frame
+	members := make([]*tickMember, len(tg.members))
+	copy(members, tg.members)
+	tg.remaining = len(members)
+	tg.locker.Unlock()
+
+	for _, m := range members {
+		m := m
+		m.g.post(func() {
+			m.g.tickFrame(frame, tg.tickMs)
+			tg.locker.Lock()
+			tg.finish(m, frame)
+			tg.locker.Unlock()
+		})
+	}
+	if len(members) > 0 {
+		<-tg.frameDone
+	}
+	if tg.barrier != nil {
+		tg.barrier(frame)
+	}
+}
+
+// 成员完成了frame帧，调用时要持有锁
+func (tg *TickGroup) finish(m *tickMember, frame int64) {
+	if m.frame >= frame {
+		return
+	}
+	m.frame = frame
+	tg.remaining--
+	if tg.remaining == 0 {
+		tg.frameDone <- struct{}{}
+	}
+}
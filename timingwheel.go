@@ -0,0 +1,303 @@
+package gpc
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	GPC_TIMER_RESOLUTION_MS = 1 // 默认的时间轮精度
+
+	wheelBits   = 6
+	wheelSlots  = 1 << wheelBits
+	wheelMask   = wheelSlots - 1
+	wheelLevels = 5 // 最大范围为64^5个刻度，精度1毫秒时约12天，更远的计时器在高层轮中多次降级
+)
+
+// 时间轮中的计时器，period大于0时为周期计时器
+type wheelTimer struct {
+	w      *timingWheel
+	expire int64 // 到期的刻度
+	period int64 // 周期，单位为刻度
+	fn     func()
+	prev   *wheelTimer
+	next   *wheelTimer
+	slot   **wheelTimer // 所在的槽，不在时间轮中时为nil
+}
+
+// 分层时间轮，实现了实际时间的Clock，所有gpc共用，代替每个调用和每个gpc各自的time.Timer、time.Ticker
+// 计时器的函数在时间轮的协程中依次执行，不能阻塞
+// 有计时器时由一个协程推进，协程睡眠到下一个有计时器的槽，没有计时器时协程退出
+type timingWheel struct {
+	resolution time.Duration
+	locker     sync.Mutex
+	start      time.Time // 刻度0对应的时间
+	current    int64     // 已推进到的刻度
+	wakeAt     int64     // 推进的协程下一次醒来的刻度
+	slots      [wheelLevels][wheelSlots]*wheelTimer
+	count      int // 时间轮中的计时器数量
+	running    bool
+	expired    []*wheelTimer
+	wake       chan struct{} // 放入了比wakeAt更早的计时器时唤醒推进的协程
+}
+
+var (
+	wheels       = make(map[time.Duration]*timingWheel)
+	wheelsLocker sync.Mutex
+)
+
+// 获得精度为resolution的共用时间轮
+func timingWheelOf(resolution time.Duration) *timingWheel {
+	if resolution <= 0 {
+		resolution = GPC_TIMER_RESOLUTION_MS * time.Millisecond
+	}
+	wheelsLocker.Lock()
+	defer wheelsLocker.Unlock()
+	w, o := wheels[resolution]
+	if !o {
+		w = newTimingWheel(resolution)
+		wheels[resolution] = w
+	}
+	return w
+}
+
+func newTimingWheel(resolution time.Duration) *timingWheel {
+	return &timingWheel{
+		resolution: resolution,
+		start:      time.Now(),
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// 实际时间
+func (w *timingWheel) Now() time.Time {
+	return time.Now()
+}
+
+// d之后在时间轮的协程中调用fn，到期时间向上取整到精度，不会提前
+func (w *timingWheel) AfterFunc(d time.Duration, fn func()) ClockTimer {
+	return w.afterFunc(d, fn)
+}
+
+func (w *timingWheel) afterFunc(d time.Duration, fn func()) *wheelTimer {
+	return w.schedule(d, 0, fn)
+}
+
+// 每隔period调用一次fn，推进落后时跳过错过的周期
+func (w *timingWheel) Every(period time.Duration, fn func()) ClockTimer {
+	return w.every(period, fn)
+}
+
+func (w *timingWheel) every(period time.Duration, fn func()) *wheelTimer {
+	return w.schedule(period, w.ticks(period), fn)
+}
+
+// 每隔period向返回的通道发送当前时间，接收方来不及接收时丢弃，和time.Ticker一样
+func (w *timingWheel) NewTicker(period time.Duration) (<-chan time.Time, ClockTimer) {
+	c := make(chan time.Time, 1)
+	t := w.every(period, func() {
+		select {
+		case c <- time.Now():
+		default:
+		}
+	})
+	return c, t
+}
+
+// 时长换算为刻度，向上取整，至少为1
+func (w *timingWheel) ticks(d time.Duration) int64 {
+	n := int64((d + w.resolution - 1) / w.resolution)
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+func (w *timingWheel) schedule(d time.Duration, period int64, fn func()) *wheelTimer {
+	t := &wheelTimer{w: w, period: period, fn: fn}
+	w.locker.Lock()
+	if !w.running {
+		// 协程停止期间没有推进，重新对齐刻度和时间
+		w.running = true
+		w.start = time.Now().Add(-time.Duration(w.current) * w.resolution)
+		go w.run()
+	}
+	// 推进的协程忙时current落后于实际时间，之后一次推进多个刻度，要从实际时间的刻度开始计算
+	// 实际时间的刻度向下取整，多加一个刻度保证不会提前到期
+	now := w.elapsed()
+	if now < w.current {
+		now = w.current
+	}
+	t.expire = now + w.ticks(d) + 1
+	w.add(t)
+	if t.expire < w.wakeAt {
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+	w.locker.Unlock()
+	return t
+}
+
+// 实际时间对应的刻度，向下取整
+func (w *timingWheel) elapsed() int64 {
+	return int64(time.Since(w.start) / w.resolution)
+}
+
+// 停止计时器，返回计时器是否还在时间轮中
+// 已经取出准备执行的计时器仍然可能执行一次
+func (t *wheelTimer) Stop() bool {
+	w := t.w
+	w.locker.Lock()
+	defer w.locker.Unlock()
+	if t.slot == nil {
+		t.period = 0
+		return false
+	}
+	w.remove(t)
+	t.period = 0
+	return true
+}
+
+// 放入对应的槽，调用时要持有锁
+func (w *timingWheel) add(t *wheelTimer) {
+	if t.expire < w.current {
+		t.expire = w.current
+	}
+	delta := t.expire - w.current
+	expire := t.expire
+	level := 0
+	for level < wheelLevels-1 && delta >= int64(1)<<(wheelBits*(level+1)) {
+		level++
+	}
+	// 超出范围的放在最高层轮，降级时重新计算
+	if max := int64(1) << (wheelBits * wheelLevels); delta >= max {
+		expire = w.current + max - 1
+	}
+	slot := &w.slots[level][(expire>>(wheelBits*level))&wheelMask]
+	t.slot = slot
+	t.prev = nil
+	t.next = *slot
+	if t.next != nil {
+		t.next.prev = t
+	}
+	*slot = t
+	w.count++
+}
+
+// 从槽中移除，调用时要持有锁
+func (w *timingWheel) remove(t *wheelTimer) {
+	if t.prev != nil {
+		t.prev.next = t.next
+	} else {
+		*t.slot = t.next
+	}
+	if t.next != nil {
+		t.next.prev = t.prev
+	}
+	t.prev, t.next, t.slot = nil, nil, nil
+	w.count--
+}
+
+// 取出槽中所有的计时器，调用时要持有锁
+func (w *timingWheel) take(slot **wheelTimer) *wheelTimer {
+	head := *slot
+	*slot = nil
+	for t := head; t != nil; t = t.next {
+		t.slot = nil
+		w.count--
+	}
+	return head
+}
+
+// 推进到target刻度，返回到期的计时器，调用时要持有锁
+func (w *timingWheel) advance(target int64) []*wheelTimer {
+	expired := w.expired[:0]
+	for w.current < target {
+		w.current++
+		// 低层轮转完一圈时，把高层轮对应槽中的计时器降级
+		for level := 1; level < wheelLevels; level++ {
+			if (w.current>>(wheelBits*(level-1)))&wheelMask != 0 {
+				break
+			}
+			for t := w.take(&w.slots[level][(w.current>>(wheelBits*level))&wheelMask]); t != nil; {
+				next := t.next
+				w.add(t)
+				t = next
+			}
+		}
+		for t := w.take(&w.slots[0][w.current&wheelMask]); t != nil; {
+			next := t.next
+			t.prev, t.next = nil, nil
+			expired = append(expired, t)
+			if t.period > 0 {
+				t.expire += t.period
+				if t.expire <= w.current {
+					t.expire += ((w.current-t.expire)/t.period + 1) * t.period
+				}
+				w.add(t)
+			}
+			t = next
+		}
+	}
+	w.expired = expired
+	return expired
+}
+
+// 下一个有计时器的槽被处理的刻度，高层轮的槽在降级时处理，调用时要持有锁
+func (w *timingWheel) nextTick() int64 {
+	next := w.current + 1
+	found := false
+	for level := 0; level < wheelLevels; level++ {
+		shift := uint(wheelBits * level)
+		for i := int64(1); i <= wheelSlots; i++ {
+			tick := ((w.current >> shift) + i) << shift
+			if found && tick >= next {
+				break
+			}
+			if w.slots[level][(tick>>shift)&wheelMask] != nil {
+				next, found = tick, true
+				break
+			}
+		}
+	}
+	return next
+}
+
+// 推进时间轮的协程，睡眠到下一个有计时器的槽，没有计时器时退出
+func (w *timingWheel) run() {
+	timer := time.NewTimer(w.resolution)
+	defer timer.Stop()
+	for {
+		w.locker.Lock()
+		expired := w.advance(w.elapsed())
+		idle := w.count == 0
+		if idle {
+			// 退出后可能马上有新的协程启动，不能再共用expired
+			w.running = false
+			w.expired = nil
+		}
+		w.wakeAt = w.nextTick()
+		wake := w.start.Add(time.Duration(w.wakeAt) * w.resolution)
+		w.locker.Unlock()
+		for i, t := range expired {
+			t.fn()
+			expired[i] = nil
+		}
+		if idle {
+			return
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(time.Until(wake))
+		select {
+		case <-timer.C:
+		case <-w.wake:
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package gpc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimingWheel(t *testing.T) {
+	w := newTimingWheel(time.Millisecond)
+	var fired []int64
+	add := func(expire, period int64) *wheelTimer {
+		tm := &wheelTimer{w: w, expire: expire, period: period}
+		tm.fn = func() {
+			fired = append(fired, w.current)
+		}
+		w.add(tm)
+		return tm
+	}
+	// 覆盖每一层轮
+	expires := []int64{1, 63, 64, 65, 4095, 4096, 4097, 300000}
+	for _, e := range expires {
+		add(e, 0)
+	}
+	stopped := add(100, 0)
+	periodic := add(10, 10)
+	if !stopped.Stop() || stopped.Stop() {
+		t.Fatalf("stop should succeed only once")
+	}
+
+	var periods int
+	for tick := int64(1); tick <= 400000; tick++ {
+		for _, tm := range w.advance(tick) {
+			tm.fn()
+		}
+		if tick == 50 {
+			periods = len(fired)
+			periodic.Stop()
+		}
+	}
+	if periods != 6 {
+		t.Fatalf("fired %v before 50", fired[:periods])
+	}
+	got := append([]int64{}, fired[:2]...)
+	for _, f := range fired[2:periods] {
+		if f%10 != 0 {
+			t.Fatalf("periodic fired at %v", f)
+		}
+	}
+	got = append(got, fired[periods:]...)
+	// 第二个是第一个周期，其余按到期时间依次触发
+	want := append([]int64{1, 10}, expires[1:]...)
+	if len(got) != len(want) {
+		t.Fatalf("fired %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("fired %v, want %v", got, want)
+		}
+	}
+	if w.count != 0 {
+		t.Fatalf("count = %v after all fired", w.count)
+	}
+}
+
+func TestTimingWheelRun(t *testing.T) {
+	w := newTimingWheel(time.Millisecond)
+	start := time.Now()
+	done := make(chan time.Duration, 1)
+	w.afterFunc(20*time.Millisecond, func() {
+		done <- time.Since(start)
+	})
+	if d := <-done; d < 20*time.Millisecond {
+		t.Fatalf("fired after %v", d)
+	}
+	c, ticker := w.NewTicker(5 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		<-c
+	}
+	ticker.Stop()
+}
+
+// 调用超时的典型用法，创建计时器后在超时之前停止
+func BenchmarkTimingWheel(b *testing.B) {
+	w := newTimingWheel(time.Millisecond)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			w.afterFunc(time.Second, func() {}).Stop()
+		}
+	})
+}
+
+func BenchmarkTimeAfterFunc(b *testing.B) {
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			time.AfterFunc(time.Second, func() {}).Stop()
+		}
+	})
+}
+
+// 大量未到期的计时器同时存在
+func BenchmarkTimingWheelPending(b *testing.B) {
+	w := newTimingWheel(time.Millisecond)
+	timers := make([]*wheelTimer, b.N)
+	b.ResetTimer()
+	for i := range timers {
+		timers[i] = w.afterFunc(time.Duration(i%10000)*time.Millisecond+time.Second, func() {})
+	}
+	for _, tm := range timers {
+		tm.Stop()
+	}
+}
+
+func BenchmarkTimeAfterFuncPending(b *testing.B) {
+	timers := make([]*time.Timer, b.N)
+	b.ResetTimer()
+	for i := range timers {
+		timers[i] = time.AfterFunc(time.Duration(i%10000)*time.Millisecond+time.Second, func() {})
+	}
+	for _, tm := range timers {
+		tm.Stop()
+	}
+}
+
+func TestTimingWheelLate(t *testing.T) {
+	w := newTimingWheel(time.Millisecond)
+	// 计时器函数阻塞推进的协程，之后一次推进多个刻度
+	idle := w.AfterFunc(time.Hour, func() {})
+	defer idle.Stop()
+	stalled := make(chan struct{})
+	w.AfterFunc(time.Millisecond, func() {
+		close(stalled)
+		time.Sleep(30 * time.Millisecond)
+	})
+	<-stalled
+	time.Sleep(20 * time.Millisecond)
+	start := time.Now()
+	fired := make(chan time.Duration, 1)
+	w.AfterFunc(20*time.Millisecond, func() {
+		fired <- time.Since(start)
+	})
+	if d := <-fired; d < 20*time.Millisecond {
+		t.Fatalf("fired after %v, want at least 20ms", d)
+	}
+}
+
+func TestTimingWheelSleep(t *testing.T) {
+	w := newTimingWheel(time.Millisecond)
+	// 只有远处的计时器时推进的协程不按精度醒来
+	idle := w.AfterFunc(time.Hour, func() {})
+	defer idle.Stop()
+	time.Sleep(10 * time.Millisecond)
+	w.locker.Lock()
+	wakeAt := w.wakeAt
+	w.locker.Unlock()
+	if wakeAt < 1000 {
+		t.Fatalf("wake at tick %v, want sleeping past tick 1000", wakeAt)
+	}
+	// 放入更早的计时器时唤醒推进的协程
+	start := time.Now()
+	fired := make(chan time.Duration, 1)
+	w.AfterFunc(10*time.Millisecond, func() {
+		fired <- time.Since(start)
+	})
+	select {
+	case d := <-fired:
+		if d < 10*time.Millisecond {
+			t.Fatalf("fired after %v, want at least 10ms", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timer not fired")
+	}
+}
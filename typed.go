@@ -0,0 +1,141 @@
+package gpc
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// 类型安全的方法句柄，参数和回复的类型在编译时检查
+// 调用时不经过interface{}转换，处理函数在gpc的Run所在协程中执行
+type Method[Arg, Reply any] struct {
+	name   string
+	target func() *gpcBase                            // 调用的gpc，在调用时获取
+	invoke func(d *data, arg Arg, reply *Reply) error // 在gpc协程中调用处理函数
+}
+
+// 在处理器中注册类型安全的处理函数，返回调用该函数的句柄
+// 同时注册了按方法名调用的处理函数，Go和Call仍然可以使用，参数类型不符时返回错误
+// 句柄调用使用该处理器的gpc，处理器不能被多个GPCFast使用
+func Register[Arg, Reply any](h *Handler, method string, handleFunc func(arg Arg, reply *Reply) error) Method[Arg, Reply] {
+	if h.shared {
+		panic("gpc: handler with typed methods cant be shared by several GPCFast")
+	}
+	h.typedMap[method] = handleFunc
+	h.handlerMap[method] = func(param interface{}, result interface{}) error {
+		var arg Arg
+		if param != nil {
+			a, ok := param.(Arg)
+			if !ok {
+				return fmt.Errorf("gpc: method %v param type %T mismatch, need %v", method, param, reflect.TypeOf(&arg).Elem())
+			}
+			arg = a
+		}
+		// Go调用没有result
+		if result == nil {
+			return handleFunc(arg, new(Reply))
+		}
+		reply, ok := result.(*Reply)
+		if !ok {
+			return fmt.Errorf("gpc: method %v result type %T mismatch, need %T", method, result, reply)
+		}
+		return handleFunc(arg, reply)
+	}
+	return Method[Arg, Reply]{
+		name: method,
+		target: func() *gpcBase {
+			if h.owner == nil {
+				return nil
+			}
+			return &h.owner.gpcBase
+		},
+		invoke: func(d *data, arg Arg, reply *Reply) error {
+			// 重启后处理器被替换，从当前的处理器中查找
+			handle, ok := h.owner.handler.typedMap[method].(func(Arg, *Reply) error)
+			if !ok {
+				return callError(method, ErrMethodNotFound)
+			}
+			return handle(arg, reply)
+		},
+	}
+}
+
+// 获得GPC服务方法的类型安全句柄，参数和回复的类型与服务方法不一致时返回错误
+// 没有回复参数的方法Reply用struct{}
+func MethodOf[Arg, Reply any](g *GPC, method string) (Method[Arg, Reply], error) {
+	_, mtype, err := g.getMethod(method)
+	if err != nil {
+		return Method[Arg, Reply]{}, err
+	}
+	argType := reflect.TypeOf((*Arg)(nil)).Elem()
+	replyType := reflect.TypeOf((*Reply)(nil))
+	if mtype.ArgType != argType {
+		return Method[Arg, Reply]{}, fmt.Errorf("gpc: method %v arg type is %v, not %v", method, mtype.ArgType, argType)
+	}
+	withResult := mtype.ReplyType != nil
+	if withResult && mtype.ReplyType != replyType {
+		return Method[Arg, Reply]{}, fmt.Errorf("gpc: method %v reply type is %v, not %v", method, mtype.ReplyType, replyType)
+	}
+	if !withResult && replyType.Elem() != reflect.TypeOf(struct{}{}) {
+		return Method[Arg, Reply]{}, fmt.Errorf("gpc: method %v has no reply, reply type must be struct{}", method)
+	}
+	return Method[Arg, Reply]{
+		name: method,
+		target: func() *gpcBase {
+			return &g.gpcBase
+		},
+		invoke: func(d *data, arg Arg, reply *Reply) error {
+			d.param = arg
+			if withResult {
+				return g.invoke(d, reply)
+			}
+			return g.invoke(d, nil)
+		},
+	}, nil
+}
+
+// 方法名
+func (m Method[Arg, Reply]) Name() string {
+	return m.name
+}
+
+// 同步调用，入队、排队和等待结果的过程中都遵守ctx的取消和超时
+// 出错时返回Reply的零值
+func (m Method[Arg, Reply]) Call(ctx context.Context, arg Arg) (Reply, error) {
+	var zero Reply
+	g := m.target()
+	if g == nil {
+		return zero, callError(m.name, ErrServiceNotFound)
+	}
+	reply := new(Reply)
+	d := &data{
+		ctx:    ctx,
+		sender: senderFrom(ctx),
+		method: m.name,
+		call: func(d *data) error {
+			return m.invoke(d, arg, reply)
+		},
+	}
+	// 超时返回时处理函数可能还在写reply，只有成功时才读取
+	if err := g.callData(ctx, d, nil, GPC_CALL_NO_TIMEOUT).wait(ctx); err != nil {
+		return zero, err
+	}
+	return *reply, nil
+}
+
+// 无返回值的调用，入队时遵守ctx的取消和超时
+func (m Method[Arg, Reply]) Go(ctx context.Context, arg Arg) error {
+	g := m.target()
+	if g == nil {
+		return callError(m.name, ErrServiceNotFound)
+	}
+	d := &data{
+		ctx:    ctx,
+		sender: senderFrom(ctx),
+		method: m.name,
+		call: func(d *data) error {
+			return m.invoke(d, arg, new(Reply))
+		},
+	}
+	return g.goData(ctx, d)
+}
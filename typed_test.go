@@ -0,0 +1,110 @@
+package gpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type SumArgs struct {
+	a, b int
+}
+
+func TestRegister(t *testing.T) {
+	handler := NewHandler()
+	total := 0
+	sum := Register(handler, "sum", func(arg SumArgs, reply *int) error {
+		*reply = arg.a + arg.b
+		return nil
+	})
+	add := Register(handler, "add", func(n int, reply *struct{}) error {
+		total += n
+		return nil
+	})
+	get := Register(handler, "get", func(_ struct{}, reply *int) error {
+		*reply = total
+		return nil
+	})
+	g := NewGPCFast(handler)
+	go g.Run()
+	defer g.Close()
+
+	ctx := context.Background()
+	n, err := sum.Call(ctx, SumArgs{a: 1, b: 2})
+	if err != nil || n != 3 {
+		t.Fatalf("sum.Call = %v, %v", n, err)
+	}
+	for i := 1; i <= 10; i++ {
+		if err := add.Go(ctx, i); err != nil {
+			t.Fatalf("add.Go: %v", err)
+		}
+	}
+	if n, err = get.Call(ctx, struct{}{}); err != nil || n != 55 {
+		t.Fatalf("get.Call = %v, %v", n, err)
+	}
+
+	// 按方法名调用仍然可用，类型不符时返回错误
+	var result int
+	if err = g.Call("sum", SumArgs{a: 2, b: 3}, &result); err != nil || result != 5 {
+		t.Fatalf("Call sum = %v, %v", result, err)
+	}
+	if err = g.Call("sum", 1, &result); err == nil {
+		t.Fatalf("Call sum with int param should fail")
+	}
+}
+
+func TestRegisterShared(t *testing.T) {
+	expectPanic := func(name string, f func()) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("%v: no panic", name)
+			}
+		}()
+		f()
+	}
+	handler := NewHandler()
+	Register(handler, "sum", func(arg SumArgs, reply *int) error {
+		return nil
+	})
+	NewGPCFast(handler)
+	expectPanic("NewGPCFast", func() {
+		NewGPCFast(handler)
+	})
+
+	handler = NewHandler()
+	NewGPCFast(handler)
+	NewGPCFast(handler)
+	expectPanic("Register", func() {
+		Register(handler, "sum", func(arg SumArgs, reply *int) error {
+			return nil
+		})
+	})
+}
+
+func TestMethodOf(t *testing.T) {
+	g, err := NewGPC(&EchoProc{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go g.Run()
+	defer g.Close()
+
+	echo, err := MethodOf[*EchoArgs, EchoReply](g, "EchoProc.Echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	reply, err := echo.Call(ctx, &EchoArgs{msg: "hi"})
+	if err != nil || reply.msg != "EchoProc.Echo:hi" {
+		t.Fatalf("echo.Call = %v, %v", reply, err)
+	}
+
+	if _, err = MethodOf[EchoArgs, EchoReply](g, "EchoProc.Echo"); err == nil {
+		t.Fatalf("MethodOf with wrong arg type should fail")
+	}
+	if _, err = MethodOf[*EchoArgs, EchoReply](g, "EchoProc.Missing"); !errors.Is(err, ErrMethodNotFound) {
+		t.Fatalf("MethodOf missing method: %v", err)
+	}
+}
@@ -0,0 +1,288 @@
+package gpc
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	GPC_IDLE_TIMEOUT_MS = 60000 // 虚拟gpc默认空闲多久后钝化
+)
+
+// 可选的钝化接口，虚拟gpc被钝化时在Run所在协程中处理完剩余消息之后、OnStop之前调用，用于保存状态
+// 因panic停止时不调用
+type Passivator interface {
+	OnPassivate()
+}
+
+// 虚拟gpc的工厂，按id创建gpc并加载状态，返回的gpc由注册表调用Run
+type VirtualFactory func(id interface{}) (Actor, error)
+
+type virtualKey struct {
+	kind string
+	id   interface{}
+}
+
+// 一次激活
+type activation struct {
+	key        virtualKey
+	actor      Actor
+	err        error
+	ready      chan struct{} // 激活完成后关闭
+	lastActive time.Time     // 最后一次通过注册表访问的时间
+	passivated bool          // 已开始钝化，等待退出
+	timer      ClockTimer
+}
+
+// 虚拟gpc的注册表，按(类型, id)管理gpc，调用时按需用类型的工厂创建并运行
+// 空闲超过idleTimeout的gpc被钝化，退出并从注册表中移除，之后的调用重新激活
+// 同一个id同时只会激活一次，并发的调用等待同一次激活；重新激活会等上一次钝化的gpc退出之后再调用工厂
+// 只有通过注册表的访问算作活动，空闲时间从最后一次Get、Call或Go开始计算，通道中还有消息时不会钝化
+type VirtualRegistry struct {
+	idleTimeout time.Duration
+	clock       Clock
+	locker      sync.Mutex
+	factories   map[string]VirtualFactory
+	actors      map[virtualKey]*activation
+	stopped     bool
+}
+
+type VirtualOption func(*VirtualRegistry)
+
+// 空闲多久后钝化，<=0时不钝化
+func IdleTimeout(timeout time.Duration) VirtualOption {
+	return func(r *VirtualRegistry) {
+		r.idleTimeout = timeout
+	}
+}
+
+// 计算空闲时间的时钟，默认为RealClock
+func VirtualClock(clock Clock) VirtualOption {
+	return func(r *VirtualRegistry) {
+		r.clock = clock
+	}
+}
+
+// 创建虚拟gpc的注册表
+func NewVirtualRegistry(options ...VirtualOption) *VirtualRegistry {
+	r := &VirtualRegistry{
+		idleTimeout: GPC_IDLE_TIMEOUT_MS * time.Millisecond,
+		factories:   make(map[string]VirtualFactory),
+		actors:      make(map[virtualKey]*activation),
+	}
+	for _, option := range options {
+		option(r)
+	}
+	if r.clock == nil {
+		r.clock = RealClock(0)
+	}
+	return r
+}
+
+// 注册一种虚拟gpc的工厂
+func (r *VirtualRegistry) RegisterKind(kind string, factory VirtualFactory) {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	r.factories[kind] = factory
+}
+
+// 获取id对应的gpc，没有激活时激活，id必须可以作为map的键
+func (r *VirtualRegistry) Get(kind string, id interface{}) (Actor, error) {
+	key := virtualKey{kind: kind, id: id}
+	for {
+		r.locker.Lock()
+		if r.stopped {
+			r.locker.Unlock()
+			return nil, ErrClosed
+		}
+		a, ok := r.actors[key]
+		if !ok {
+			factory, ok := r.factories[kind]
+			if !ok {
+				r.locker.Unlock()
+				return nil, ErrServiceNotFound
+			}
+			a = &activation{key: key, ready: make(chan struct{})}
+			r.actors[key] = a
+			r.locker.Unlock()
+			r.activate(a, factory)
+		} else {
+			r.locker.Unlock()
+		}
+		<-a.ready
+		if a.err != nil {
+			return nil, a.err
+		}
+		r.locker.Lock()
+		passivated := a.passivated
+		if !passivated {
+			a.lastActive = r.clock.Now()
+		}
+		r.locker.Unlock()
+		if !passivated {
+			return a.actor, nil
+		}
+		// 等钝化的gpc退出后重新激活
+		<-a.actor.Done()
+	}
+}
+
+// 同步调用id对应的gpc，gpc恰好被钝化时重新激活后再调用一次
+func (r *VirtualRegistry) Call(kind string, id interface{}, methodName string, param interface{}, result interface{}) error {
+	return r.retry(kind, id, func(actor Actor) error {
+		return actor.Call(methodName, param, result)
+	})
+}
+
+// 带上下文的同步调用
+func (r *VirtualRegistry) CallContext(ctx context.Context, kind string, id interface{}, methodName string, param interface{}, result interface{}) error {
+	return r.retry(kind, id, func(actor Actor) error {
+		return actor.CallContext(ctx, methodName, param, result)
+	})
+}
+
+// 无返回值调用id对应的gpc
+func (r *VirtualRegistry) Go(kind string, id interface{}, methodName string, param interface{}) error {
+	return r.retry(kind, id, func(actor Actor) error {
+		return actor.GoContext(context.Background(), methodName, param)
+	})
+}
+
+func (r *VirtualRegistry) retry(kind string, id interface{}, call func(actor Actor) error) error {
+	var err error
+	for i := 0; i < 2; i++ {
+		var actor Actor
+		if actor, err = r.Get(kind, id); err != nil {
+			return err
+		}
+		// 只在gpc已关闭、消息没有处理时重试，处理函数返回的ErrClosed直接返回
+		if err = call(actor); !actor.base().rejected(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// 立即钝化id对应的gpc并等待退出，没有激活时返回nil
+func (r *VirtualRegistry) Passivate(ctx context.Context, kind string, id interface{}) error {
+	r.locker.Lock()
+	a, ok := r.actors[virtualKey{kind: kind, id: id}]
+	r.locker.Unlock()
+	if !ok {
+		return nil
+	}
+	<-a.ready
+	if a.err != nil {
+		return nil
+	}
+	r.locker.Lock()
+	a.passivated = true
+	r.locker.Unlock()
+	a.actor.base().passivate()
+	return a.actor.Stop(ctx)
+}
+
+// 已激活的gpc数
+func (r *VirtualRegistry) Active() int {
+	r.locker.Lock()
+	defer r.locker.Unlock()
+	return len(r.actors)
+}
+
+// 钝化所有gpc并等待退出，之后不能再激活
+func (r *VirtualRegistry) Stop(ctx context.Context) error {
+	r.locker.Lock()
+	r.stopped = true
+	actors := make([]*activation, 0, len(r.actors))
+	for _, a := range r.actors {
+		actors = append(actors, a)
+	}
+	r.locker.Unlock()
+	var err error
+	for _, a := range actors {
+		<-a.ready
+		if a.err != nil {
+			continue
+		}
+		r.locker.Lock()
+		a.passivated = true
+		r.locker.Unlock()
+		a.actor.base().passivate()
+		if e := a.actor.Stop(ctx); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// 调用工厂创建gpc并运行
+func (r *VirtualRegistry) activate(a *activation, factory VirtualFactory) {
+	defer close(a.ready)
+	a.actor, a.err = func() (actor Actor, err error) {
+		defer func() {
+			if e := recover(); e != nil {
+				err = newPanicError("VirtualFactory", e)
+			}
+		}()
+		return factory(a.key.id)
+	}()
+	if a.err != nil {
+		r.locker.Lock()
+		delete(r.actors, a.key)
+		r.locker.Unlock()
+		return
+	}
+	r.locker.Lock()
+	a.lastActive = r.clock.Now()
+	if r.idleTimeout > 0 {
+		a.timer = r.clock.AfterFunc(r.idleTimeout, func() {
+			r.checkIdle(a)
+		})
+	}
+	r.locker.Unlock()
+	go a.actor.Run()
+	// 钝化、关闭或者因panic退出时都从注册表中移除
+	a.actor.base().onExit(func(reason error) {
+		r.locker.Lock()
+		defer r.locker.Unlock()
+		if r.actors[a.key] == a {
+			delete(r.actors, a.key)
+		}
+		if a.timer != nil {
+			a.timer.Stop()
+		}
+	})
+}
+
+// 空闲时间到期，期间有访问时重新计时
+func (r *VirtualRegistry) checkIdle(a *activation) {
+	r.locker.Lock()
+	if a.passivated || r.actors[a.key] != a {
+		r.locker.Unlock()
+		return
+	}
+	idle := r.clock.Now().Sub(a.lastActive)
+	if idle < r.idleTimeout || a.actor.base().queued() > 0 {
+		wait := r.idleTimeout - idle
+		if wait <= 0 {
+			wait = r.idleTimeout
+		}
+		a.timer = r.clock.AfterFunc(wait, func() {
+			r.checkIdle(a)
+		})
+		r.locker.Unlock()
+		return
+	}
+	a.passivated = true
+	r.locker.Unlock()
+	// 在计时器的协程中，不等待退出
+	a.actor.base().passivate()
+}
+
+// 钝化，关闭后Run退出前调用OnPassivate
+func (g *gpcBase) passivate() {
+	atomic.StoreInt32(&g.passivating, 1)
+	g.Close()
+}
@@ -0,0 +1,137 @@
+package gpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// 按id保存计数的存储，钝化时保存，激活时加载
+type counterStore struct {
+	locker      sync.Mutex
+	saved       map[interface{}]int
+	activations int32
+}
+
+func (s *counterStore) factory(id interface{}) (Actor, error) {
+	atomic.AddInt32(&s.activations, 1)
+	s.locker.Lock()
+	count := s.saved[id]
+	s.locker.Unlock()
+	handler := NewHandler()
+	handler.RegisterHandle("incr", func(param interface{}, result interface{}) error {
+		count++
+		*(result.(*int)) = count
+		return nil
+	})
+	handler.SetPassivateHandle(func() {
+		s.locker.Lock()
+		s.saved[id] = count
+		s.locker.Unlock()
+	})
+	return NewGPCFast(handler), nil
+}
+
+func waitActive(t *testing.T, r *VirtualRegistry, n int) {
+	for i := 0; r.Active() != n; i++ {
+		if i > 1000 {
+			t.Fatalf("active = %v, want %v", r.Active(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestVirtualRegistry(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	store := &counterStore{saved: make(map[interface{}]int)}
+	r := NewVirtualRegistry(IdleTimeout(time.Minute), VirtualClock(clock))
+	r.RegisterKind("counter", store.factory)
+
+	// 并发的调用只激活一次
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var n int
+			if err := r.Call("counter", 1, "incr", nil, &n); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&store.activations); n != 1 {
+		t.Fatalf("activations = %v", n)
+	}
+
+	// 访问会重新计时
+	var n int
+	clock.Advance(40 * time.Second)
+	r.Call("counter", 1, "incr", nil, &n)
+	clock.Advance(40 * time.Second)
+	if r.Active() != 1 {
+		t.Fatalf("passivated while in use")
+	}
+
+	// 空闲后钝化并保存，再次调用时重新激活并加载
+	clock.Advance(time.Minute)
+	waitActive(t, r, 0)
+	if store.saved[1] != 51 {
+		t.Fatalf("saved %v", store.saved[1])
+	}
+	if err := r.Call("counter", 1, "incr", nil, &n); err != nil || n != 52 {
+		t.Fatalf("n = %v, err = %v", n, err)
+	}
+	if n := atomic.LoadInt32(&store.activations); n != 2 {
+		t.Fatalf("activations = %v", n)
+	}
+
+	// 主动钝化
+	if err := r.Passivate(context.Background(), "counter", 1); err != nil {
+		t.Fatal(err)
+	}
+	waitActive(t, r, 0)
+	if store.saved[1] != 52 {
+		t.Fatalf("saved %v", store.saved[1])
+	}
+
+	if _, err := r.Get("unknown", 1); !errors.Is(err, ErrServiceNotFound) {
+		t.Fatalf("err = %v", err)
+	}
+	r.Call("counter", 2, "incr", nil, &n)
+	if err := r.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if store.saved[2] != 1 {
+		t.Fatalf("saved %v", store.saved[2])
+	}
+	if err := r.Call("counter", 2, "incr", nil, &n); !errors.Is(err, ErrClosed) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestVirtualRetry(t *testing.T) {
+	// 处理函数返回的ErrClosed不重试
+	var calls int32
+	r := NewVirtualRegistry()
+	defer r.Stop(context.Background())
+	r.RegisterKind("persist", func(id interface{}) (Actor, error) {
+		handler := NewHandler()
+		handler.RegisterHandle("save", func(param interface{}, result interface{}) error {
+			atomic.AddInt32(&calls, 1)
+			return fmt.Errorf("persist: %w", ErrClosed)
+		})
+		return NewGPCFast(handler), nil
+	})
+	var n int
+	if err := r.Call("persist", 1, "save", nil, &n); !errors.Is(err, ErrClosed) {
+		t.Fatalf("err = %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Fatalf("handler called %v times", n)
+	}
+}
@@ -0,0 +1,90 @@
+package gpc
+
+// 监视的gpc退出的通知
+type Terminated struct {
+	Actor  Actor // 退出的gpc
+	Reason error // 退出的原因，正常关闭为nil
+}
+
+// 可选的监视接口，监视的gpc退出时作为消息投递到本gpc的通道，在Run所在协程中调用
+// 处理时panic和处理函数一样交给监督策略
+type Watcher interface {
+	OnTerminated(t Terminated)
+}
+
+// 监视target，target退出时收到Terminated，已经退出时立即收到，每次Watch只收到一次
+// 本gpc退出时自动取消所有监视
+func (g *gpcBase) Watch(target Actor) {
+	t := target.base()
+	if t == g {
+		return
+	}
+	g.watchLocker.Lock()
+	if _, ok := g.watching[t]; ok {
+		g.watchLocker.Unlock()
+		return
+	}
+	if g.watching == nil {
+		g.watching = make(map[*gpcBase]struct{})
+	}
+	g.watching[t] = struct{}{}
+	g.watchLocker.Unlock()
+
+	t.exitLocker.Lock()
+	if !t.exited {
+		if t.watchers == nil {
+			t.watchers = make(map[*gpcBase]struct{})
+		}
+		t.watchers[g] = struct{}{}
+		t.exitLocker.Unlock()
+		return
+	}
+	reason := t.stopReason
+	t.exitLocker.Unlock()
+	g.terminated(t, reason)
+}
+
+// 取消监视target，之后不会再收到target的Terminated，包括已经投递还没处理的
+func (g *gpcBase) Unwatch(target Actor) {
+	t := target.base()
+	g.watchLocker.Lock()
+	delete(g.watching, t)
+	g.watchLocker.Unlock()
+	t.exitLocker.Lock()
+	delete(t.watchers, g)
+	t.exitLocker.Unlock()
+}
+
+// 投递t退出的通知，处理时已经取消监视的丢弃
+func (g *gpcBase) terminated(t *gpcBase, reason error) {
+	g.post(func() {
+		g.watchLocker.Lock()
+		_, ok := g.watching[t]
+		delete(g.watching, t)
+		g.watchLocker.Unlock()
+		if !ok {
+			return
+		}
+		if g.onTerminateFunc != nil {
+			g.onTerminateFunc(Terminated{Actor: t.self, Reason: reason})
+		} else if reason != nil && t.parent == g {
+			g.logChildFailure(t, reason)
+		}
+	})
+}
+
+// 退出时通知监视者，并取消对其他gpc的监视
+func (g *gpcBase) notifyWatchers(watchers map[*gpcBase]struct{}) {
+	for w := range watchers {
+		w.terminated(g, g.stopReason)
+	}
+	g.watchLocker.Lock()
+	watching := g.watching
+	g.watching = nil
+	g.watchLocker.Unlock()
+	for t := range watching {
+		t.exitLocker.Lock()
+		delete(t.watchers, g)
+		t.exitLocker.Unlock()
+	}
+}
@@ -0,0 +1,82 @@
+package gpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	terminated := make(chan Terminated, 10)
+	handler := NewHandler()
+	handler.SetTerminatedHandle(func(t Terminated) {
+		terminated <- t
+	})
+	watcher := NewGPCFast(handler)
+	go watcher.Run()
+	defer watcher.Close()
+
+	newTarget := func() *GPCFast {
+		g := NewGPCFast(newCounterHandler(), Supervise(func(*PanicError) Directive {
+			return Stop
+		}))
+		go g.Run()
+		return g
+	}
+	expect := func(target Actor) Terminated {
+		select {
+		case term := <-terminated:
+			if term.Actor != target {
+				t.Fatalf("terminated %v, want %v", term.Actor, target)
+			}
+			return term
+		case <-time.After(time.Second):
+			t.Fatalf("no terminated")
+		}
+		return Terminated{}
+	}
+
+	// 正常关闭
+	closed := newTarget()
+	watcher.Watch(closed)
+	watcher.Watch(closed)
+	closed.Stop(context.Background())
+	if term := expect(closed); term.Reason != nil {
+		t.Fatalf("reason %v", term.Reason)
+	}
+
+	// 因panic停止
+	crashed := newTarget()
+	watcher.Watch(crashed)
+	var n int
+	crashed.Call("boom", nil, &n)
+	if term := expect(crashed); !errors.Is(term.Reason, ErrPanic) {
+		t.Fatalf("reason %v", term.Reason)
+	}
+
+	// 取消监视后不再通知，已经退出的立即通知
+	unwatched := newTarget()
+	watcher.Watch(unwatched)
+	watcher.Unwatch(unwatched)
+	unwatched.Stop(context.Background())
+	watcher.Watch(closed)
+	expect(closed)
+	select {
+	case term := <-terminated:
+		t.Fatalf("unexpected %v", term)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	// 监视者退出时取消监视
+	target := newTarget()
+	watcher.Watch(target)
+	watcher.Stop(context.Background())
+	target.exitLocker.Lock()
+	watchers := len(target.watchers)
+	target.exitLocker.Unlock()
+	if watchers != 0 {
+		t.Fatalf("%v watchers after watcher exited", watchers)
+	}
+	target.Stop(context.Background())
+}